@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"sort"
+	"time"
+)
+
+// isAdmin проверяет токен администратора. Без AdminToken команды отключены
+func isAdmin(msg map[string]interface{}) bool {
+	token, _ := msg["token"].(string)
+	if config.AdminToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) == 1
+}
+
+// handleAdmin выполняет команду администратора:
+// {"type": "admin", "token": "...", "command": "snapshot" | "list" | "team" | "buff" | "pause" | "resume"}.
+// Команда "team" переводит игрока "id" в команду "team", команда "buff" выдаёт
+// игроку "id" усиление "buff" силой "magnitude" на "duration" секунд
+func handleAdmin(addr *net.UDPAddr, msg map[string]interface{}) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !isAdmin(msg) {
+		log.Printf("Команда администратора от %s отклонена: неверный токен", addr)
+		sendUDPMessage(addr, map[string]interface{}{"error": "unauthorized"})
+		return
+	}
+
+	command, _ := msg["command"].(string)
+	log.Printf("Команда администратора %q от %s", command, addr)
+	switch command {
+	case "snapshot":
+		sendUDPMessage(addr, adminSnapshot(clock()))
+	case "list":
+		sendUDPMessage(addr, map[string]interface{}{
+			"type":    "list",
+			"players": adminPlayers(clock()),
+		})
+	case "pause", "resume":
+		changed := false
+		if command == "pause" {
+			changed = pauseMatch(clock())
+		} else {
+			changed = unpauseMatch(clock())
+		}
+		sendUDPMessage(addr, map[string]interface{}{
+			"type":    command,
+			"changed": changed,
+			"phase":   matchPhase,
+		})
+	case "team":
+		id, _ := msg["id"].(float64)
+		team, _ := msg["team"].(float64)
+		player, ok := players[int(id)]
+		if !ok || !teamsEnabled() || int(team) < 1 || int(team) > config.Teams {
+			sendUDPMessage(addr, map[string]interface{}{"error": "invalid_team"})
+			return
+		}
+		setPlayerTeam(player, int(team))
+		sendUDPMessage(addr, map[string]interface{}{
+			"type": "team",
+			"id":   player.ID,
+			"team": player.Team,
+		})
+	case "buff":
+		id, _ := msg["id"].(float64)
+		buffType, _ := msg["buff"].(string)
+		magnitude, _ := msg["magnitude"].(float64)
+		duration, _ := msg["duration"].(float64)
+		player, ok := players[int(id)]
+		if !ok || !isBuffType(buffType) || !isFinite(magnitude) || magnitude < 0 || !isFinite(duration) || duration <= 0 {
+			sendUDPMessage(addr, map[string]interface{}{"error": "invalid_buff"})
+			return
+		}
+		now := clock()
+		grantBuff(player, buffType, magnitude, time.Duration(duration*float64(time.Second)), now)
+		sendUDPMessage(addr, map[string]interface{}{
+			"type":  "buff",
+			"id":    player.ID,
+			"buffs": buffsState(player, now),
+		})
+	default:
+		sendUDPMessage(addr, map[string]interface{}{
+			"error":   "unknown_command",
+			"command": command,
+		})
+	}
+}
+
+// adminPlayers описывает игроков вместе с внутренними полями и данными
+// соединения по возрастанию ID, вызывается под mutex
+func adminPlayers(now time.Time) []map[string]interface{} {
+	ids := make([]int, 0, len(players))
+	for id := range players {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	result := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		player := players[id]
+		entry := map[string]interface{}{
+			"id":               player.ID,
+			"name":             player.Name,
+			"x":                player.X,
+			"y":                player.Y,
+			"points":           player.Points,
+			"hp":               player.HP,
+			"ping":             player.Ping,
+			"color":            player.Color,
+			"team":             player.Team,
+			"buffs":            buffsState(player, now),
+			"stats":            player.Stats,
+			"protectedUntil":   player.ProtectedUntil,
+			"lastSeen":         player.LastSeen,
+			"missedHeartbeats": player.MissedHeartbeats,
+			"lastActivity":     player.LastActivity,
+			"lastInputSeq":     player.LastInputSeq,
+			"inputQueue":       len(player.InputQueue),
+			"velocity":         []float64{player.VX, player.VY},
+			"cooldowns": map[string]interface{}{
+				"push":   max(actionCooldown(player, config.PushCooldown, now)-now.Sub(player.LastPushTime), 0).Seconds(),
+				"pull":   max(actionCooldown(player, config.PullCooldown, now)-now.Sub(player.LastPullTime), 0).Seconds(),
+				"swap":   max(actionCooldown(player, config.SwapCooldown, now)-now.Sub(player.LastSwapTime), 0).Seconds(),
+				"freeze": max(actionCooldown(player, config.FreezeCooldown, now)-now.Sub(player.LastFreezeTime), 0).Seconds(),
+				"dash":   max(actionCooldown(player, config.DashCooldown, now)-now.Sub(player.LastDashTime), 0).Seconds(),
+			},
+			"pendingReliable": len(reliableQueues[id]),
+			"writeFailures":   writeFailures[id],
+		}
+		if addr, ok := clientAddrs[id]; ok {
+			sent, received := connectionTraffic(addr)
+			entry["addr"] = addr.String()
+			entry["bytesSent"] = sent
+			entry["bytesReceived"] = received
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// adminSnapshot собирает полный снимок внутреннего состояния сервера,
+// вызывается под mutex, поэтому снимок согласован
+func adminSnapshot(now time.Time) map[string]interface{} {
+	points := make([]map[string]interface{}, 0, len(capturePoints))
+	for _, cp := range capturePoints {
+		points = append(points, map[string]interface{}{
+			"point":        cp,
+			"enterTime":    cp.EnterTime,
+			"captureStart": cp.CaptureStart,
+			"holdStart":    cp.HoldStart,
+			"occupants":    cp.Occupants,
+			"contributors": cp.Contributors,
+		})
+	}
+
+	spectatorAddrs := make([]string, 0, len(spectators))
+	for key := range spectators {
+		spectatorAddrs = append(spectatorAddrs, key)
+	}
+	sort.Strings(spectatorAddrs)
+
+	return map[string]interface{}{
+		"type":          "snapshot",
+		"time":          now,
+		"match":         matchStatus(),
+		"players":       adminPlayers(now),
+		"capturePoints": points,
+		"spectators":    spectatorAddrs,
+		"bytesSent":     totalTraffic.sent.Load(),
+		"bytesReceived": totalTraffic.received.Load(),
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestAdminSnapshotReflectsState(t *testing.T) {
+	resetGame(t)
+	config.AdminToken = "secret"
+	owner, _ := addTestPlayer(t, 100, 700)
+	other, _ := addTestPlayer(t, 900, 100)
+	owner.Points, other.Points = 7, 2
+	completeCapture(&capturePoints[1], owner)
+	admin := newTestClient(t)
+
+	handleAdmin(admin.addr(), map[string]interface{}{"type": "admin", "token": "wrong", "command": "snapshot"})
+	admin.recvError("unauthorized")
+
+	handleAdmin(admin.addr(), map[string]interface{}{"type": "admin", "token": "secret", "command": "snapshot"})
+	snapshot := admin.recv("snapshot")
+
+	list, _ := snapshot["players"].([]interface{})
+	if len(list) != 2 {
+		t.Fatalf("игроков в снимке: %d", len(list))
+	}
+	for i, want := range []*Player{owner, other} {
+		entry := list[i].(map[string]interface{})
+		if msgFloat(t, entry, "id") != float64(want.ID) || msgFloat(t, entry, "points") != float64(want.Points) ||
+			msgFloat(t, entry, "x") != want.X || msgFloat(t, entry, "y") != want.Y {
+			t.Fatalf("игрок %d в снимке: %v", want.ID, entry)
+		}
+		if entry["addr"] != clientAddrs[want.ID].String() {
+			t.Fatalf("адрес игрока %d в снимке: %v", want.ID, entry["addr"])
+		}
+		if _, ok := entry["cooldowns"].(map[string]interface{}); !ok {
+			t.Fatalf("нет перезарядок игрока %d: %v", want.ID, entry)
+		}
+	}
+
+	points, _ := snapshot["capturePoints"].([]interface{})
+	if len(points) != len(capturePoints) {
+		t.Fatalf("точек в снимке: %d", len(points))
+	}
+	captured := points[1].(map[string]interface{})
+	point := captured["point"].(map[string]interface{})
+	if point["isCaptured"] != true || msgFloat(t, point, "capturingPlayer") != float64(owner.ID) {
+		t.Fatalf("захваченная точка в снимке: %v", point)
+	}
+	if captured["captureStart"] == "0001-01-01T00:00:00Z" {
+		t.Fatalf("нет начала удержания: %v", captured)
+	}
+	if points[0].(map[string]interface{})["point"].(map[string]interface{})["isCaptured"] != false {
+		t.Fatal("нейтральная точка в снимке захвачена")
+	}
+}
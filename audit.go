@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	auditQueueSize     = 1024        // Записей в очереди журнала аудита
+	auditFlushInterval = time.Second // Как часто буфер журнала сбрасывается на диск
+)
+
+var (
+	// Очередь записей журнала аудита. Запись идёт в отдельной горутине,
+	// чтобы диск не задерживал игровой цикл
+	auditEntries chan []byte
+	// Закрывается, когда запись журнала завершена
+	auditDone chan struct{}
+
+	// Записи, отброшенные из-за переполненной очереди, защищено mutex
+	auditDropped int
+)
+
+// auditPlayer описывает игрока в записи журнала аудита
+func auditPlayer(player *Player) map[string]interface{} {
+	if player == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":   player.ID,
+		"name": player.Name,
+		"key":  playerKey(player),
+	}
+}
+
+// auditPlayerID описывает игрока по ID. Если игрок уже удалён (например,
+// точка освобождается из-за его ухода), в записи остаётся только ID.
+// Вызывается под mutex
+func auditPlayerID(playerID int) map[string]interface{} {
+	if player, ok := players[playerID]; ok {
+		return auditPlayer(player)
+	}
+	return map[string]interface{}{"id": playerID}
+}
+
+// audit добавляет событие в журнал аудита, если он включён. Вызывается под mutex
+func audit(event string, fields map[string]interface{}) {
+	if auditEntries == nil {
+		return
+	}
+	fields["time"] = time.Now().UTC()
+	fields["event"] = event
+	data, err := json.Marshal(fields)
+	if err != nil {
+		recordMarshalError("записи аудита", err)
+		return
+	}
+
+	select {
+	case auditEntries <- data:
+	default:
+		auditDropped++
+		if auditDropped == 1 || auditDropped%100 == 0 {
+			log.Printf("Очередь журнала аудита переполнена, отброшено записей: %d", auditDropped)
+		}
+	}
+}
+
+// startAudit открывает журнал аудита на дозапись и запускает запись в фоне
+func startAudit(path string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	auditEntries = make(chan []byte, auditQueueSize)
+	auditDone = make(chan struct{})
+	go auditWriter(file, auditEntries, auditDone)
+	return nil
+}
+
+// stopAudit дописывает оставшиеся записи на диск и закрывает журнал,
+// вызывается под mutex
+func stopAudit() {
+	if auditEntries == nil {
+		return
+	}
+	close(auditEntries)
+	auditEntries = nil
+	<-auditDone
+}
+
+// auditWriter пишет записи построчно в формате JSON и периодически сбрасывает буфер
+func auditWriter(file *os.File, entries <-chan []byte, done chan<- struct{}) {
+	defer close(done)
+	w := bufio.NewWriter(file)
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data, ok := <-entries:
+			if !ok {
+				w.Flush()
+				file.Close()
+				return
+			}
+			w.Write(data)
+			w.WriteByte('\n')
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				log.Println("Ошибка записи журнала аудита:", err)
+			}
+		}
+	}
+}
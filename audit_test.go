@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCaptureAppendsAuditEntry(t *testing.T) {
+	resetGame(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte(`{"event":"earlier"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := startAudit(path); err != nil {
+		t.Fatal(err)
+	}
+	player, _ := addTestPlayer(t, 100, 700)
+	player.Name = "capturer"
+	completeCapture(&capturePoints[1], player)
+	stopAudit()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("строка журнала не JSON: %q", scanner.Text())
+		}
+		entries = append(entries, entry)
+	}
+
+	// Журнал дописывается, а не перезаписывается
+	if len(entries) != 2 || entries[0]["event"] != "earlier" {
+		t.Fatalf("записи журнала: %v", entries)
+	}
+	entry := entries[1]
+	if entry["event"] != "capture" || msgFloat(t, entry, "point") != float64(capturePoints[1].ID) {
+		t.Fatalf("запись о захвате: %v", entry)
+	}
+	who, _ := entry["player"].(map[string]interface{})
+	if msgFloat(t, who, "id") != float64(player.ID) || who["name"] != "capturer" || who["key"] != playerKey(player) {
+		t.Fatalf("игрок в записи: %v", who)
+	}
+	if ts, _ := entry["time"].(string); ts == "" {
+		t.Fatalf("нет времени в записи: %v", entry)
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// traffic — счётчики байтов одного соединения или всего сервера
+type traffic struct {
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+var (
+	// Суммарный трафик сервера, включая пакеты от неизвестных адресов
+	totalTraffic traffic
+
+	// Трафик подключённых клиентов по адресу. Счётчики атомарные, поэтому
+	// читатель сокета и рассылка не берут mutex ради учёта байтов
+	connTraffic sync.Map // string -> *traffic
+)
+
+// trackConnection заводит счётчики трафика для адреса клиента, если их ещё нет
+func trackConnection(addr *net.UDPAddr) {
+	connTraffic.LoadOrStore(addr.String(), &traffic{})
+}
+
+// untrackConnection забывает счётчики трафика ушедшего клиента
+func untrackConnection(key string) {
+	connTraffic.Delete(key)
+}
+
+// countReceived учитывает принятый пакет. Для неизвестных адресов счётчики не
+// заводятся, чтобы поток мусорных пакетов не раздувал таблицу
+func countReceived(addr *net.UDPAddr, n int) {
+	totalTraffic.received.Add(int64(n))
+	if t, ok := connTraffic.Load(addr.String()); ok {
+		t.(*traffic).received.Add(int64(n))
+	}
+}
+
+// countSent учитывает отправленный пакет
+func countSent(addr *net.UDPAddr, n int) {
+	totalTraffic.sent.Add(int64(n))
+	if t, ok := connTraffic.Load(addr.String()); ok {
+		t.(*traffic).sent.Add(int64(n))
+	}
+}
+
+// writeTo отправляет данные на адрес и учитывает отправленные байты. Клиенту
+// с DTLS-сессией данные уходят зашифрованными через неё
+func writeTo(data []byte, addr *net.UDPAddr) error {
+	var n int
+	var err error
+	if session, ok := dtlsSessions.Load(addr.String()); ok {
+		n, err = session.(net.Conn).Write(data)
+	} else {
+		n, err = conn.WriteToUDP(data, addr)
+	}
+	countSent(addr, n)
+	return err
+}
+
+// connectionTraffic возвращает отправленные и принятые байты соединения
+func connectionTraffic(addr *net.UDPAddr) (sent, received int64) {
+	if t, ok := connTraffic.Load(addr.String()); ok {
+		return t.(*traffic).sent.Load(), t.(*traffic).received.Load()
+	}
+	return 0, 0
+}
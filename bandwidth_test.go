@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSentBytesCountPayload(t *testing.T) {
+	resetGame(t)
+	_, client := addTestPlayer(t, 400, 400)
+	client.recv("joined")
+	sentBefore, _ := connectionTraffic(client.addr())
+	totalBefore := totalTraffic.sent.Load()
+
+	gameTick(time.Now(), 10*time.Millisecond, true)
+
+	// Всё, что пришло клиенту за тик, и есть отправленная ему нагрузка
+	received := 0
+	buf := make([]byte, 64*1024)
+	client.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		n, err := client.conn.Read(buf)
+		if err != nil {
+			break
+		}
+		received += n
+	}
+	if received == 0 {
+		t.Fatal("клиент не получил состояние")
+	}
+	sent, _ := connectionTraffic(client.addr())
+	if sent-sentBefore != int64(received) {
+		t.Fatalf("счётчик соединения вырос на %d, отправлено %d байт", sent-sentBefore, received)
+	}
+	if total := totalTraffic.sent.Load() - totalBefore; total != int64(received) {
+		t.Fatalf("общий счётчик вырос на %d, отправлено %d байт", total, received)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Компактный двоичный формат для частых сообщений. Все числа little-endian.
+//
+// Снимок позиций (сервер → клиент):
+//
+//	[0]    binaryPositions
+//	[1:3]  uint16 — количество игроков
+//	далее по 13 байт на игрока: int32 id, float32 x, float32 y, byte флаги
+//
+// Перемещение (клиент → сервер):
+//
+//	[0]     binaryMove
+//	[1:5]   int32 id
+//	[5:9]   float32 x
+//	[9:13]  float32 y
+//	[13:17] uint32 seq
+//	[17]    byte флаги (используется только binaryFlagFlipX)
+const (
+	binaryPositions byte = 0x01
+	binaryMove      byte = 0x02
+
+	binaryPlayerSize = 13
+	binaryMoveSize   = 18
+)
+
+// Флаги игрока в двоичном формате
+const (
+	binaryFlagFlipX     byte = 1 << 0
+	binaryFlagProtected byte = 1 << 1
+	binaryFlagFrozen    byte = 1 << 2
+)
+
+// Кодировки, которые клиент выбирает при подключении
+const (
+	encodingJSON   = "json"
+	encodingBinary = "binary"
+)
+
+// encodePositions дописывает к buf двоичный снимок позиций игроков
+func encodePositions(buf []byte, state []Player) []byte {
+	buf = append(buf, binaryPositions)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(state)))
+	for _, p := range state {
+		var flags byte
+		if p.FlipX {
+			flags |= binaryFlagFlipX
+		}
+		if p.Protected {
+			flags |= binaryFlagProtected
+		}
+		if p.Frozen > 0 {
+			flags |= binaryFlagFrozen
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(p.ID)))
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(p.X)))
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(p.Y)))
+		buf = append(buf, flags)
+	}
+	return buf
+}
+
+// decodeMove разбирает двоичное перемещение в сообщение того же вида, что
+// присылают JSON-клиенты, чтобы дальше оно шло общим путём
+func decodeMove(data []byte) (map[string]interface{}, error) {
+	if len(data) != binaryMoveSize || data[0] != binaryMove {
+		return nil, fmt.Errorf("некорректное двоичное перемещение длиной %d", len(data))
+	}
+	return map[string]interface{}{
+		"id":    float64(int32(binary.LittleEndian.Uint32(data[1:5]))),
+		"x":     float64(math.Float32frombits(binary.LittleEndian.Uint32(data[5:9]))),
+		"y":     float64(math.Float32frombits(binary.LittleEndian.Uint32(data[9:13]))),
+		"seq":   float64(binary.LittleEndian.Uint32(data[13:17])),
+		"flipX": data[17]&binaryFlagFlipX != 0,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// decodePositions разбирает двоичный снимок позиций так, как это делает клиент
+func decodePositions(t *testing.T, data []byte) []Player {
+	t.Helper()
+	if len(data) < 3 || data[0] != binaryPositions {
+		t.Fatalf("не двоичный снимок: % x", data)
+	}
+	n := int(binary.LittleEndian.Uint16(data[1:3]))
+	if len(data) != 3+n*binaryPlayerSize {
+		t.Fatalf("снимок из %d игроков длиной %d", n, len(data))
+	}
+	result := make([]Player, n)
+	for i := range result {
+		b := data[3+i*binaryPlayerSize:]
+		result[i] = Player{
+			ID:        int(int32(binary.LittleEndian.Uint32(b[0:4]))),
+			X:         float64(math.Float32frombits(binary.LittleEndian.Uint32(b[4:8]))),
+			Y:         float64(math.Float32frombits(binary.LittleEndian.Uint32(b[8:12]))),
+			FlipX:     b[12]&binaryFlagFlipX != 0,
+			Protected: b[12]&binaryFlagProtected != 0,
+		}
+		if b[12]&binaryFlagFrozen != 0 {
+			result[i].Frozen = 1
+		}
+	}
+	return result
+}
+
+// encodeMove собирает двоичное перемещение так, как это делает клиент
+func encodeMove(id int, x, y float32, seq uint32, flipX bool) []byte {
+	buf := []byte{binaryMove}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(id)))
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(x))
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(y))
+	buf = binary.LittleEndian.AppendUint32(buf, seq)
+	if flipX {
+		return append(buf, binaryFlagFlipX)
+	}
+	return append(buf, 0)
+}
+
+func TestBinaryPositionsRoundTrip(t *testing.T) {
+	state := []Player{
+		{ID: 1, X: 12.5, Y: 700.25, FlipX: true},
+		{ID: 42, X: 0, Y: -3.75, Protected: true, Frozen: 0.5},
+	}
+	got := decodePositions(t, encodePositions(nil, state))
+	if len(got) != len(state) {
+		t.Fatalf("игроков %d", len(got))
+	}
+	for i, want := range state {
+		if got[i].ID != want.ID || got[i].X != want.X || got[i].Y != want.Y ||
+			got[i].FlipX != want.FlipX || got[i].Protected != want.Protected || (got[i].Frozen > 0) != (want.Frozen > 0) {
+			t.Fatalf("игрок %d: %+v, ожидался %+v", i, got[i], want)
+		}
+	}
+}
+
+func TestBinaryMoveRoundTrip(t *testing.T) {
+	msg, err := decodeMove(encodeMove(7, 123.5, 456.25, 99, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg["id"] != 7.0 || msg["x"] != 123.5 || msg["y"] != 456.25 || msg["seq"] != 99.0 || msg["flipX"] != true {
+		t.Fatalf("разобрано %v", msg)
+	}
+	if _, err := decodeMove(encodeMove(7, 1, 2, 3, false)[:binaryMoveSize-1]); err == nil {
+		t.Fatal("обрезанное перемещение принято")
+	}
+}
+
+func TestBinaryClientReceivesBinaryPositions(t *testing.T) {
+	resetGame(t)
+	client := newTestClient(t)
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "encoding": encodingBinary})
+	client.recv("joined")
+	player := players[nextPlayerID]
+
+	// Двоичное перемещение идёт общим путём
+	handlePacket(client.addr(), encodeMove(player.ID, 420, 410, 1, true), false)
+	if player.X != 420 || player.Y != 410 || !player.FlipX {
+		t.Fatalf("двоичное перемещение не применено: (%v, %v)", player.X, player.Y)
+	}
+
+	gameTick(time.Now(), 10*time.Millisecond, false)
+	buf := make([]byte, 64*1024)
+	client.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	n, err := client.conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodePositions(t, buf[:n])
+	if len(got) != 1 || got[0].ID != player.ID || got[0].X != 420 || got[0].Y != 410 || !got[0].FlipX {
+		t.Fatalf("двоичный снимок: %+v", got)
+	}
+	// Между ключевыми кадрами JSON-состояние двоичному клиенту не отправляется
+	client.expectNone(100*time.Millisecond, isState)
+}
@@ -0,0 +1,135 @@
+package main
+
+import "time"
+
+// Типы усилений
+const (
+	buffShield   = "shield"   // Неуязвимость к push/pull/swap
+	buffSpeed    = "speed"    // Прибавка к MaxSpeed, доля (0.5 — на 50% быстрее)
+	buffCooldown = "cooldown" // Сокращение перезарядки действий, доля
+)
+
+// Правила наложения усиления того же типа
+const (
+	stackReplace = "replace"      // Новое усиление заменяет действующее
+	stackExtend  = "extend"       // Длительность суммируется, сила — наибольшая из двух
+	stackCapped  = "stack-capped" // Сила суммируется до MaxMagnitude, длительность обновляется
+)
+
+// Наибольшее сокращение перезарядки, чтобы действия не стали мгновенными
+const maxCooldownReduction = 0.9
+
+// Buff — временное усиление игрока
+type Buff struct {
+	Type      string    `json:"type"`
+	Magnitude float64   `json:"magnitude"`
+	ExpiresIn float64   `json:"expiresIn"` // Сколько секунд ещё действует, заполняется при отправке
+	ExpiresAt time.Time `json:"-"`         // Окончание действия
+}
+
+// BuffRule — правило наложения усиления в конфигурации
+type BuffRule struct {
+	Stacking     string  `json:"stacking"`     // replace, extend или stack-capped
+	MaxMagnitude float64 `json:"maxMagnitude"` // Предел суммарной силы для stack-capped (0 — без предела)
+}
+
+// isBuffType проверяет, что усиление такого типа существует
+func isBuffType(buffType string) bool {
+	switch buffType {
+	case buffShield, buffSpeed, buffCooldown:
+		return true
+	}
+	return false
+}
+
+// grantBuff выдаёт игроку усиление. Если усиление того же типа уже действует,
+// они объединяются по правилу из config.Buffs. Вызывается под mutex
+func grantBuff(player *Player, buffType string, magnitude float64, duration time.Duration, now time.Time) {
+	expiresAt := now.Add(duration)
+
+	for i := range player.Buffs {
+		buff := &player.Buffs[i]
+		if buff.Type != buffType || !now.Before(buff.ExpiresAt) {
+			continue
+		}
+
+		rule := config.Buffs[buffType]
+		switch rule.Stacking {
+		case stackExtend:
+			buff.Magnitude = max(buff.Magnitude, magnitude)
+			buff.ExpiresAt = buff.ExpiresAt.Add(duration)
+		case stackCapped:
+			buff.Magnitude += magnitude
+			if rule.MaxMagnitude > 0 {
+				buff.Magnitude = min(buff.Magnitude, rule.MaxMagnitude)
+			}
+			if expiresAt.After(buff.ExpiresAt) {
+				buff.ExpiresAt = expiresAt
+			}
+		default:
+			buff.Magnitude = magnitude
+			buff.ExpiresAt = expiresAt
+		}
+		return
+	}
+
+	player.Buffs = append(player.Buffs, Buff{
+		Type:      buffType,
+		Magnitude: magnitude,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// buffMagnitude возвращает силу действующего усиления или 0, вызывается под mutex
+func buffMagnitude(player *Player, buffType string, now time.Time) float64 {
+	for _, buff := range player.Buffs {
+		if buff.Type == buffType && now.Before(buff.ExpiresAt) {
+			return buff.Magnitude
+		}
+	}
+	return 0
+}
+
+// hasBuff проверяет, действует ли на игрока усиление, вызывается под mutex
+func hasBuff(player *Player, buffType string, now time.Time) bool {
+	for _, buff := range player.Buffs {
+		if buff.Type == buffType && now.Before(buff.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionCooldown возвращает перезарядку действия с учётом скина и усиления
+// cooldown, вызывается под mutex
+func actionCooldown(player *Player, base Duration, now time.Time) time.Duration {
+	reduction := min(buffMagnitude(player, buffCooldown, now), maxCooldownReduction)
+	return time.Duration(float64(base) * player.Attrs.Cooldown * (1 - reduction))
+}
+
+// buffsState копирует усиления игрока для отправки клиенту с оставшимся
+// временем действия вместо часов сервера, вызывается под mutex
+func buffsState(player *Player, now time.Time) []Buff {
+	if len(player.Buffs) == 0 {
+		return nil
+	}
+	state := make([]Buff, len(player.Buffs))
+	for i, buff := range player.Buffs {
+		buff.ExpiresIn = max(buff.ExpiresAt.Sub(now), 0).Seconds()
+		state[i] = buff
+	}
+	return state
+}
+
+// expireBuffs удаляет истёкшие усиления, вызывается под mutex из игрового тика
+func expireBuffs(now time.Time) {
+	for _, player := range players {
+		active := player.Buffs[:0]
+		for _, buff := range player.Buffs {
+			if now.Before(buff.ExpiresAt) {
+				active = append(active, buff)
+			}
+		}
+		player.Buffs = active
+	}
+}
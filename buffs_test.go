@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuffStackingPolicies(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		rule          BuffRule
+		wantMagnitude float64
+		wantExpires   time.Duration
+	}{
+		// Новое усиление заменяет действующее целиком
+		{BuffRule{Stacking: stackReplace}, 0.2, 5 * time.Second},
+		// Длительности складываются, сила — наибольшая
+		{BuffRule{Stacking: stackExtend}, 0.3, 15 * time.Second},
+		// Сила складывается до предела, длительность — наибольшая
+		{BuffRule{Stacking: stackCapped, MaxMagnitude: 0.4}, 0.4, 10 * time.Second},
+	} {
+		resetGame(t)
+		config.Buffs = map[string]BuffRule{buffSpeed: tt.rule}
+		player, _ := addTestPlayer(t, 400, 400)
+
+		grantBuff(player, buffSpeed, 0.3, 10*time.Second, now)
+		grantBuff(player, buffSpeed, 0.2, 5*time.Second, now)
+		if len(player.Buffs) != 1 {
+			t.Fatalf("%s: усилений %d, ожидалось одно", tt.rule.Stacking, len(player.Buffs))
+		}
+		buff := player.Buffs[0]
+		if buff.Magnitude != tt.wantMagnitude || !buff.ExpiresAt.Equal(now.Add(tt.wantExpires)) {
+			t.Errorf("%s: сила %v до %v, ожидалась %v до %v", tt.rule.Stacking,
+				buff.Magnitude, buff.ExpiresAt.Sub(now), tt.wantMagnitude, tt.wantExpires)
+		}
+	}
+}
+
+func TestExpiredBuffNotStacked(t *testing.T) {
+	resetGame(t)
+	config.Buffs = map[string]BuffRule{buffSpeed: {Stacking: stackCapped}}
+	player, _ := addTestPlayer(t, 400, 400)
+	now := time.Now()
+
+	grantBuff(player, buffSpeed, 0.3, time.Second, now.Add(-2*time.Second))
+	grantBuff(player, buffSpeed, 0.2, time.Second, now)
+	if got := buffMagnitude(player, buffSpeed, now); got != 0.2 {
+		t.Fatalf("сила %v, истёкшее усиление сложилось с новым", got)
+	}
+}
+
+func TestAdminGrantsBuff(t *testing.T) {
+	resetGame(t)
+	config.AdminToken = "secret"
+	player, _ := addTestPlayer(t, 400, 400)
+	admin := newTestClient(t)
+
+	handleAdmin(admin.addr(), map[string]interface{}{"type": "admin", "token": "secret", "command": "buff",
+		"id": float64(player.ID), "buff": buffShield, "magnitude": 1.0, "duration": 5.0})
+	admin.recv("buff")
+	if !isProtected(player, time.Now()) {
+		t.Fatal("игрок не получил щит")
+	}
+
+	handleAdmin(admin.addr(), map[string]interface{}{"type": "admin", "token": "secret", "command": "buff",
+		"id": float64(player.ID), "buff": "invisible", "duration": 5.0})
+	admin.recvError("invalid_buff")
+}
+
+func TestStatusReflectsCooldownBuff(t *testing.T) {
+	resetGame(t)
+	config.PushCooldown = Duration(2 * time.Second)
+	player, client := addTestPlayer(t, 400, 400)
+	status := func() map[string]interface{} {
+		t.Helper()
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "action": "status"})
+		actions, _ := client.recv("status")["actions"].(map[string]interface{})
+		push, _ := actions["push"].(map[string]interface{})
+		return push
+	}
+
+	push := status()
+	if msgFloat(t, push, "cooldown") != 2 || msgFloat(t, push, "range") != config.PushRange {
+		t.Fatalf("push без усиления: %v", push)
+	}
+	grantBuff(player, buffCooldown, 0.25, time.Minute, time.Now())
+	if cooldown := msgFloat(t, status(), "cooldown"); cooldown != 1.5 {
+		t.Fatalf("перезарядка с усилением %v с, ожидалось 1.5", cooldown)
+	}
+}
+
+func TestSnapshotBuffExpiresIn(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	now := time.Now()
+	grantBuff(player, buffSpeed, 0.5, 5*time.Second, now.Add(-2*time.Second))
+
+	gameTick(now, 10*time.Millisecond, true)
+	list, _ := client.recvState()["players"].([]interface{})
+	state, _ := list[0].(map[string]interface{})
+	buffs, _ := state["buffs"].([]interface{})
+	if len(buffs) != 1 {
+		t.Fatalf("усиления в состоянии: %v", state["buffs"])
+	}
+	buff, _ := buffs[0].(map[string]interface{})
+	if _, ok := buff["expiresAt"]; ok {
+		t.Fatalf("в состоянии часы сервера: %v", buff)
+	}
+	// Клиент получает остаток в секундах, независимо от своих часов
+	if left := msgFloat(t, buff, "expiresIn"); left < 2.9 || left > 3 {
+		t.Fatalf("осталось %v с, ожидалось 3", left)
+	}
+}
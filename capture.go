@@ -0,0 +1,443 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	captureTime   = 5 * time.Second // Сколько нужно простоять в зоне, чтобы захватить точку
+	scoreInterval = 5 * time.Second // Как часто владелец получает очки за точку
+)
+
+// Правила для точки, в зоне которой несколько игроков
+const (
+	contestRuleContest = "contest"  // Захват сбрасывается, пока в зоне больше одного игрока
+	contestRuleFirstIn = "first-in" // Захват продолжает тот, кто вошёл в зону раньше
+)
+
+// Правила, по которым игрок считается стоящим на точке
+const (
+	captureRuleCenter  = "center"  // Центр игрока внутри зоны
+	captureRuleOverlap = "overlap" // Круг игрока касается зоны
+	captureRuleInside  = "inside"  // Круг игрока целиком внутри зоны
+)
+
+// Режимы игры
+const (
+	modePoints = "points" // Очки приносят все удерживаемые точки
+	modeKOTH   = "koth"   // "Царь горы": одна точка, очки только пока владелец стоит на ней
+)
+
+// loadCapturePoints создаёт нейтральные точки захвата по конфигурации,
+// вызывается под mutex
+func loadCapturePoints() {
+	configured := config.CapturePoints
+	if config.Mode == modeKOTH && len(configured) > 1 {
+		log.Printf("Режим %q: используется только первая из %d точек захвата", modeKOTH, len(configured))
+		configured = configured[:1]
+	}
+
+	capturePoints = make([]CapturePoint, 0, len(configured))
+	for i, pc := range configured {
+		weight := pc.ScoreWeight
+		if weight <= 0 {
+			weight = 1
+		}
+		maxHold := time.Duration(config.MaxHoldTime)
+		if pc.MaxHoldTime > 0 {
+			maxHold = time.Duration(pc.MaxHoldTime)
+		}
+		capturePoints = append(capturePoints, CapturePoint{
+			ID:              i + 1,
+			X:               pc.X,
+			Y:               pc.Y,
+			Radius:          pc.Radius,
+			ScoreWeight:     weight,
+			MaxHold:         maxHold,
+			RequiredPlayers: pc.RequiredPlayers,
+			Active:          true,
+		})
+	}
+
+	nextRotation = time.Time{}
+	if rotationEnabled() {
+		rotateActivePoints(clock())
+	}
+}
+
+// Время следующей смены активных точек, защищено mutex
+var nextRotation time.Time
+
+// rotationEnabled проверяет, активна ли лишь часть точек
+func rotationEnabled() bool {
+	return config.ActivePoints > 0 && config.ActivePoints < len(capturePoints)
+}
+
+// updateRotation меняет набор активных точек по расписанию, вызывается под mutex
+func updateRotation(now time.Time) {
+	if rotationEnabled() && time.Duration(config.RotationInterval) > 0 && !now.Before(nextRotation) {
+		rotateActivePoints(now)
+	}
+}
+
+// rotateActivePoints случайно выбирает ActivePoints активных точек.
+// Выключенная точка становится нейтральной, прогресс захвата на ней
+// сбрасывается. Вызывается под mutex
+func rotateActivePoints(now time.Time) {
+	nextRotation = now.Add(time.Duration(config.RotationInterval))
+
+	active := make(map[int]bool, config.ActivePoints)
+	for _, i := range rng.Perm(len(capturePoints))[:config.ActivePoints] {
+		active[i] = true
+	}
+
+	for i := range capturePoints {
+		cp := &capturePoints[i]
+		if cp.Active == active[i] {
+			continue
+		}
+		cp.Active = active[i]
+
+		event := "activated"
+		if !cp.Active {
+			event = "deactivated"
+			if cp.IsCaptured {
+				neutralizePoint(cp, "deactivated")
+			}
+			cp.EnterTime = time.Time{}
+			cp.CurrentCapturingPlayer = 0
+			clear(cp.Contributors)
+		}
+		broadcastEvent(map[string]interface{}{
+			"type":  "capture",
+			"event": event,
+			"point": cp.ID,
+		})
+	}
+}
+
+// updateZoneOccupants сравнивает нахождение игроков в зоне точки с прошлой
+// проверкой и рассылает события входа и выхода, вызывается под mutex
+func updateZoneOccupants(cp *CapturePoint, now time.Time) {
+	if cp.Occupants == nil {
+		cp.Occupants = make(map[int]time.Time)
+	}
+
+	for id, player := range players {
+		_, wasInside := cp.Occupants[id]
+		inside := isPlayerInZone(player, cp)
+		switch {
+		case inside && !wasInside:
+			cp.Occupants[id] = now
+			broadcastZoneEvent(cp, id, "enter")
+		case !inside && wasInside:
+			delete(cp.Occupants, id)
+			broadcastZoneEvent(cp, id, "exit")
+		}
+	}
+
+	// Удалённые игроки просто забываются
+	for id := range cp.Occupants {
+		if _, ok := players[id]; !ok {
+			delete(cp.Occupants, id)
+		}
+	}
+}
+
+// checkZoneExits обновляет присутствие в зонах каждый тик gameLoop, а не
+// только при проверке точек раз в 100 мс: игрок, которого вытолкнули из зоны,
+// сразу перестаёт продвигать захват. Прогресс отряда на точке с
+// RequiredPlayers > 1 принадлежит команде, и его судьбу решает
+// updateCapturePoints. Вызывается под mutex
+func checkZoneExits(now time.Time) {
+	if !matchActive() {
+		return
+	}
+	for i := range capturePoints {
+		cp := &capturePoints[i]
+		updateZoneOccupants(cp, now)
+		if cp.RequiredPlayers > 1 {
+			continue
+		}
+		// Прогресс замирает в момент выхода и продолжится, если захватчик
+		// вернётся в течение CaptureGrace
+		if id := cp.CurrentCapturingPlayer; id != 0 && cp.PausedAt.IsZero() && !isPlayerInZone(players[id], cp) {
+			cp.PausedAt = now
+		}
+	}
+}
+
+// broadcastZoneEvent рассылает событие входа или выхода игрока из зоны точки
+func broadcastZoneEvent(cp *CapturePoint, playerID int, event string) {
+	broadcastEvent(map[string]interface{}{
+		"type":   "zone",
+		"event":  event,
+		"player": playerID,
+		"point":  cp.ID,
+	})
+}
+
+// neutralizePoint снимает захват с точки и сообщает об этом клиентам,
+// вызывается под mutex
+func neutralizePoint(cp *CapturePoint, reason string) {
+	owner := cp.CapturingPlayer
+	cp.IsCaptured = false
+	cp.CapturingPlayer = 0
+	cp.OwnerTeam = 0
+	cp.CaptureStart = time.Time{}
+	cp.HoldStart = time.Time{}
+	cp.StreakMultiplier = 0
+	cp.DrainStart = time.Time{}
+
+	audit("neutralize", map[string]interface{}{
+		"point":  cp.ID,
+		"player": auditPlayerID(owner),
+		"reason": reason,
+	})
+	broadcastEvent(map[string]interface{}{
+		"type":   "capture",
+		"event":  "neutralized",
+		"point":  cp.ID,
+		"player": owner,
+		"reason": reason,
+	})
+}
+
+// enforceOwnedPointsCap освобождает самые давно захваченные точки игрока,
+// чтобы вместе с только что захваченной точкой captured их было не больше
+// MaxOwnedPoints. Вызывается под mutex
+func enforceOwnedPointsCap(playerID int, captured *CapturePoint) {
+	if config.MaxOwnedPoints <= 0 {
+		return
+	}
+	for {
+		var owned int
+		var oldest *CapturePoint
+		for i := range capturePoints {
+			cp := &capturePoints[i]
+			if cp == captured || !cp.IsCaptured || cp.CapturingPlayer != playerID {
+				continue
+			}
+			owned++
+			if oldest == nil || cp.HoldStart.Before(oldest.HoldStart) {
+				oldest = cp
+			}
+		}
+		if owned < config.MaxOwnedPoints {
+			return
+		}
+		neutralizePoint(oldest, "owned_cap")
+	}
+}
+
+// trackContributors запоминает, когда незащищённые игроки последний раз были в
+// зоне точки, и забывает тех, кто покинул её дольше AssistWindow назад.
+// Вызывается под mutex после updateZoneOccupants
+func trackContributors(cp *CapturePoint, now time.Time) {
+	if cp.Contributors == nil {
+		cp.Contributors = make(map[int]time.Time)
+	}
+	for id := range cp.Occupants {
+		if player, ok := players[id]; ok && !isProtected(player, now) {
+			cp.Contributors[id] = now
+		}
+	}
+
+	window := time.Duration(config.AssistWindow)
+	for id, lastSeen := range cp.Contributors {
+		if _, ok := players[id]; !ok || now.Sub(lastSeen) > window {
+			delete(cp.Contributors, id)
+		}
+	}
+}
+
+// creditAssists засчитывает помощь в захвате всем недавним участникам, кроме
+// завершившего захват, и начинает учёт участников заново. Вызывается под mutex
+func creditAssists(cp *CapturePoint, capturerID int, now time.Time) {
+	window := time.Duration(config.AssistWindow)
+	for id, lastSeen := range cp.Contributors {
+		if id == capturerID || now.Sub(lastSeen) > window {
+			continue
+		}
+		if player, ok := players[id]; ok {
+			player.Stats.Assists++
+			logDebug("Игрок %d помог захватить точку %d", id, cp.ID)
+		}
+	}
+	clear(cp.Contributors)
+}
+
+// holderScores проверяет, может ли владелец точки получать за неё очки. В
+// режиме "царь горы" для этого нужно стоять в зоне. Вызывается под mutex
+func holderScores(cp *CapturePoint) bool {
+	if config.Mode != modeKOTH {
+		return true
+	}
+	return isPlayerInZone(players[cp.CapturingPlayer], cp)
+}
+
+// zoneCapturer определяет, кто захватывает точку. contested — в зоне больше
+// одного игрока и по правилу ContestRule захват сбрасывается. Защищённые после
+// появления игроки не участвуют в захвате. Вызывается под mutex после
+// updateZoneOccupants
+func zoneCapturer(cp *CapturePoint, now time.Time) (capturer *Player, contested bool) {
+	inZone := playersOnPoint(cp, now)
+	if cp.RequiredPlayers > 1 {
+		return squadCapturer(cp, inZone)
+	}
+
+	switch {
+	case len(inZone) == 0:
+		return nil, false
+	case len(inZone) == 1:
+		return inZone[0], false
+	case config.ContestRule == contestRuleFirstIn:
+		// Преимущество обороняющегося: раньше вошедший продолжает захват,
+		// при одновременном входе — игрок с меньшим ID
+		first := inZone[0]
+		for _, player := range inZone[1:] {
+			entered, firstEntered := cp.Occupants[player.ID], cp.Occupants[first.ID]
+			if entered.Before(firstEntered) || (entered.Equal(firstEntered) && player.ID < first.ID) {
+				first = player
+			}
+		}
+		return first, false
+	default:
+		return nil, true
+	}
+}
+
+// playersOnPoint возвращает незащищённых игроков в зоне точки, вызывается под mutex
+func playersOnPoint(cp *CapturePoint, now time.Time) []*Player {
+	var inZone []*Player
+	for _, player := range players {
+		if isPlayerInZone(player, cp) && !isProtected(player, now) {
+			inZone = append(inZone, player)
+		}
+	}
+	return inZone
+}
+
+// squadCapturer определяет захватчика точки, для которой нужно не меньше
+// RequiredPlayers игроков одной команды в зоне. Захват ведётся от имени одного
+// из них: прежнего захватчика, если он ещё в зоне, иначе раньше всех вошедшего.
+// Игроки разных команд (или несколько игроков без команд) оспаривают точку.
+// Вызывается под mutex
+func squadCapturer(cp *CapturePoint, inZone []*Player) (capturer *Player, contested bool) {
+	if len(inZone) == 0 {
+		return nil, false
+	}
+	team := inZone[0].Team
+	for _, player := range inZone[1:] {
+		if team == 0 || player.Team != team {
+			return nil, true
+		}
+	}
+	if len(inZone) < cp.RequiredPlayers {
+		return nil, false
+	}
+
+	first := inZone[0]
+	for _, player := range inZone {
+		if player.ID == cp.CurrentCapturingPlayer {
+			return player, false
+		}
+		entered, firstEntered := cp.Occupants[player.ID], cp.Occupants[first.ID]
+		if entered.Before(firstEntered) || (entered.Equal(firstEntered) && player.ID < first.ID) {
+			first = player
+		}
+	}
+	return first, false
+}
+
+// understaffed проверяет, что на точке стоят только игроки одной команды, но
+// их меньше RequiredPlayers. Прогресс захвата в этом случае замирает, а не
+// сбрасывается. Вызывается под mutex
+func understaffed(cp *CapturePoint, now time.Time) bool {
+	if cp.RequiredPlayers <= 1 {
+		return false
+	}
+	inZone := playersOnPoint(cp, now)
+	if len(inZone) == 0 || len(inZone) >= cp.RequiredPlayers {
+		return false
+	}
+	for _, player := range inZone[1:] {
+		if player.Team == 0 || player.Team != inZone[0].Team {
+			return false
+		}
+	}
+	return true
+}
+
+// enemiesNear считает незащищённых противников capturer в пределах radius от
+// центра точки. В командной игре союзники противниками не считаются.
+// Вызывается под mutex
+func enemiesNear(cp *CapturePoint, capturer *Player, radius float64, now time.Time) int {
+	n := 0
+	for id, player := range players {
+		if id == capturer.ID || isProtected(player, now) {
+			continue
+		}
+		if teamsEnabled() && player.Team == capturer.Team {
+			continue
+		}
+		if math.Hypot(player.X-cp.X, player.Y-cp.Y) <= radius {
+			n++
+		}
+	}
+	return n
+}
+
+// captureClock возвращает момент, на который считаются таймеры точек. Пока
+// матч остановлен, таймеры стоят: они сдвигаются при продолжении матча.
+// Вызывается под mutex
+func captureClock(now time.Time) time.Time {
+	if matchPhase == phaseWaiting || matchPhase == phasePaused {
+		return pausedAt
+	}
+	return now
+}
+
+// setCaptureTimers заполняет в снимке точки относительные таймеры. Клиенту не
+// нужны абсолютные метки времени сервера: с другими часами они бессмысленны.
+// Вызывается под mutex
+func setCaptureTimers(cp *CapturePoint, now time.Time) {
+	if !cp.EnterTime.IsZero() {
+		elapsed := now.Sub(cp.EnterTime)
+		if !cp.PausedAt.IsZero() {
+			// Захват замер из-за нехватки игроков
+			elapsed = cp.PausedAt.Sub(cp.EnterTime)
+		}
+		cp.Progress = min(max(elapsed.Seconds()/captureTime.Seconds(), 0), 1)
+	}
+	if cp.IsCaptured {
+		if drain := time.Duration(config.DrainTime); drain > 0 && !cp.DrainStart.IsZero() {
+			cp.Drain = min(max(now.Sub(cp.DrainStart).Seconds()/drain.Seconds(), 0), 1)
+		}
+		cp.HeldFor = max(now.Sub(cp.HoldStart), 0).Seconds()
+		cp.NextScoreIn = max(scoreInterval-now.Sub(cp.CaptureStart), 0).Seconds()
+	}
+}
+
+// drainPoint ослабляет захват точки, на которой не стоит владелец: через
+// DrainTime без владельца точка становится нейтральной. Вернувшийся владелец
+// восстанавливает захват полностью. Возвращает true, если точка стала
+// нейтральной. Вызывается под mutex
+func drainPoint(cp *CapturePoint, now time.Time) bool {
+	drain := time.Duration(config.DrainTime)
+	if drain <= 0 || isPlayerInZone(players[cp.CapturingPlayer], cp) {
+		cp.DrainStart = time.Time{}
+		return false
+	}
+	if cp.DrainStart.IsZero() {
+		cp.DrainStart = now
+	}
+	if now.Sub(cp.DrainStart) < drain {
+		return false
+	}
+	log.Printf("Точка %d слишком долго оставалась без владельца и стала нейтральной", cp.ID)
+	neutralizePoint(cp, "drained")
+	return true
+}
@@ -0,0 +1,702 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// ownPoint отдаёт точку игроку так, будто он удерживает её held и ему как раз
+// пора получить очки
+func ownPoint(cp *CapturePoint, player *Player, held time.Duration) {
+	now := time.Now()
+	cp.IsCaptured = true
+	cp.CapturingPlayer = player.ID
+	cp.OwnerTeam = player.Team
+	cp.HoldStart = now.Add(-held)
+	cp.CaptureStart = now.Add(-scoreInterval)
+}
+
+func TestStreakMultiplier(t *testing.T) {
+	resetGame(t)
+	for _, tt := range []struct {
+		held time.Duration
+		want int
+	}{
+		{0, 1},
+		{14 * time.Second, 1},
+		{15 * time.Second, 2},
+		{31 * time.Second, 3},
+		{10 * time.Minute, 3},
+	} {
+		if got := streakMultiplier(tt.held); got != tt.want {
+			t.Errorf("удержание %v: множитель %d, ожидался %d", tt.held, got, tt.want)
+		}
+	}
+}
+
+func TestLongHoldScoresMore(t *testing.T) {
+	resetGame(t)
+	veteran, _ := addTestPlayer(t, 100, 700)
+	newcomer, _ := addTestPlayer(t, 900, 100)
+	ownPoint(&capturePoints[0], veteran, 40*time.Second)
+	ownPoint(&capturePoints[1], newcomer, scoreInterval)
+
+	updateCapturePoints()
+	if veteran.Points != 3 || newcomer.Points != 1 {
+		t.Fatalf("очки за интервал: долгое удержание %d, свежий захват %d", veteran.Points, newcomer.Points)
+	}
+}
+
+// collectMessages собирает сообщения, пришедшие клиенту за timeout, для
+// которых match возвращает true
+func collectMessages(c *testClient, timeout time.Duration, match func(map[string]interface{}) bool) []map[string]interface{} {
+	var msgs []map[string]interface{}
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, ok := c.next(time.Until(deadline), match)
+		if !ok {
+			return msgs
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestZoneEnterExitEventsOnce(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 100, 700)
+	cp := &capturePoints[0]
+
+	player.X, player.Y = cp.X, cp.Y
+	for i := 0; i < 3; i++ {
+		updateZoneOccupants(cp, time.Now())
+		checkZoneExits(time.Now())
+	}
+	player.X, player.Y = 100, 700
+	for i := 0; i < 3; i++ {
+		updateZoneOccupants(cp, time.Now())
+		checkZoneExits(time.Now())
+	}
+
+	events := collectMessages(client, 100*time.Millisecond, hasType("zone"))
+	if len(events) != 2 || events[0]["event"] != "enter" || events[1]["event"] != "exit" {
+		t.Fatalf("события зоны: %v", events)
+	}
+	for _, event := range events {
+		if event["point"] != float64(cp.ID) || event["player"] != float64(player.ID) {
+			t.Fatalf("событие %v", event)
+		}
+	}
+}
+
+// completeCapture ставит игрока на точку так, будто он простоял в зоне всё
+// время захвата, и проводит проверку точек
+func completeCapture(cp *CapturePoint, player *Player) {
+	player.X, player.Y = cp.X, cp.Y
+	cp.CurrentCapturingPlayer = player.ID
+	cp.EnterTime = time.Now().Add(-captureTime - 10*time.Millisecond)
+	updateCapturePoints()
+}
+
+func TestOwnedPointsCapReleasesOldest(t *testing.T) {
+	resetGame(t)
+	config.MaxOwnedPoints = 1
+	player, client := addTestPlayer(t, 100, 700)
+
+	completeCapture(&capturePoints[0], player)
+	completeCapture(&capturePoints[1], player)
+	if capturePoints[0].IsCaptured {
+		t.Fatal("первая точка осталась захваченной сверх лимита")
+	}
+	if !capturePoints[1].IsCaptured || capturePoints[1].CapturingPlayer != player.ID {
+		t.Fatal("вторая точка не захвачена")
+	}
+
+	msg, ok := client.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "neutralized" })
+	if !ok || msg["reason"] != "owned_cap" || msgFloat(t, msg, "point") != float64(capturePoints[0].ID) {
+		t.Fatalf("событие освобождения точки: %v", msg)
+	}
+}
+
+func TestScoreWeightMultipliesPoints(t *testing.T) {
+	resetGame(t)
+	config.CapturePoints[1].ScoreWeight = 3
+	loadCapturePoints()
+	normal, _ := addTestPlayer(t, 100, 700)
+	heavy, _ := addTestPlayer(t, 900, 100)
+	ownPoint(&capturePoints[0], normal, scoreInterval)
+	ownPoint(&capturePoints[1], heavy, scoreInterval)
+
+	updateCapturePoints()
+	if normal.Points != 1 || heavy.Points != 3 {
+		t.Fatalf("очки за интервал: вес 1 — %d, вес 3 — %d", normal.Points, heavy.Points)
+	}
+}
+
+// TestCaptureSnapshotWhileUpdating снимает состояние точек под mutex и
+// сериализует его уже без блокировки, пока точки захватываются и
+// нейтрализуются. Снимок не должен разделять данные с игрой. Запускать с -race
+func TestCaptureSnapshotWhileUpdating(t *testing.T) {
+	resetGame(t)
+	player, _ := addTestPlayer(t, 100, 700)
+	addTestPlayer(t, 900, 100)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			mutex.Lock()
+			cp := &capturePoints[i%len(capturePoints)]
+			completeCapture(cp, player)
+			checkZoneExits(time.Now())
+			neutralizePoint(cp, "test")
+			mutex.Unlock()
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		mutex.Lock()
+		points := getCapturePointsState()
+		mutex.Unlock()
+		data, err := json.Marshal(points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded []map[string]interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil || len(decoded) != len(points) {
+			t.Fatalf("снимок точек %s: %v", data, err)
+		}
+	}
+	<-done
+}
+
+func TestPushOutPausesCapture(t *testing.T) {
+	resetGame(t)
+	config.PushStrength = 5000
+	config.CaptureGrace = Duration(time.Hour)
+	cp := &capturePoints[0]
+	capturer, _ := addTestPlayer(t, cp.X, cp.Y+10)
+	pusher, _ := addTestPlayer(t, cp.X, cp.Y-cp.Radius-15)
+
+	// Захват почти завершён, когда захватчика выталкивают из зоны
+	updateCapturePoints()
+	cp.EnterTime = time.Now().Add(-captureTime + 100*time.Millisecond)
+	applyPush(pusher)
+	stepKnockback(t, capturer)
+	if isPlayerInZone(capturer, cp) {
+		t.Fatalf("захватчик остался в зоне: (%v, %v)", capturer.X, capturer.Y)
+	}
+	exited := time.Now()
+	checkZoneExits(exited)
+	if cp.PausedAt.IsZero() || cp.EnterTime.IsZero() {
+		t.Fatal("прогресс вытолкнутого захватчика не замер")
+	}
+	left := captureTime - exited.Sub(cp.EnterTime)
+
+	// Вне зоны прогресс не растёт
+	time.Sleep(150 * time.Millisecond)
+	updateCapturePoints()
+	if cp.IsCaptured || cp.PausedAt.IsZero() {
+		t.Fatalf("захват продвинулся без захватчика: захвачена=%v", cp.IsCaptured)
+	}
+
+	// Вернувшись, захватчик продолжает с того же места
+	capturer.X, capturer.Y = cp.X, cp.Y
+	updateCapturePoints()
+	if cp.IsCaptured || !cp.PausedAt.IsZero() {
+		t.Fatalf("захват не продолжился: захвачена=%v", cp.IsCaptured)
+	}
+	if remaining := captureTime - time.Since(cp.EnterTime); remaining > left || remaining < left-50*time.Millisecond {
+		t.Fatalf("до захвата осталось %v, перед выходом было %v", remaining, left)
+	}
+	time.Sleep(left)
+	updateCapturePoints()
+	if !cp.IsCaptured || cp.CapturingPlayer != capturer.ID {
+		t.Fatal("захват не завершён с прежним прогрессом")
+	}
+}
+
+func TestPushOutProgressResetAfterGrace(t *testing.T) {
+	resetGame(t)
+	cp := &capturePoints[0]
+	capturer, _ := addTestPlayer(t, cp.X, cp.Y)
+	updateCapturePoints()
+
+	// Захватчик не вернулся за CaptureGrace: прогресс сбрасывается
+	capturer.X, capturer.Y = cp.X+cp.Radius+20, cp.Y
+	checkZoneExits(time.Now())
+	cp.PausedAt = time.Now().Add(-time.Duration(config.CaptureGrace))
+	updateCapturePoints()
+	if cp.CurrentCapturingPlayer != 0 || !cp.EnterTime.IsZero() || !cp.PausedAt.IsZero() {
+		t.Fatal("прогресс не сброшен после окна возвращения")
+	}
+}
+
+func TestKingOfTheHillScoresOnlyOnPoint(t *testing.T) {
+	resetGame(t)
+	config.Mode = modeKOTH
+	loadCapturePoints()
+	if len(capturePoints) != 1 {
+		t.Fatalf("в режиме %q точек %d, ожидалась одна", modeKOTH, len(capturePoints))
+	}
+	cp := &capturePoints[0]
+	holder, _ := addTestPlayer(t, cp.X, cp.Y)
+	other, _ := addTestPlayer(t, 900, 700)
+
+	ownPoint(cp, holder, scoreInterval)
+	updateCapturePoints()
+	if holder.Points != 1 || other.Points != 0 {
+		t.Fatalf("очки на точке: владелец %d, другой %d", holder.Points, other.Points)
+	}
+
+	// Владелец сошёл с точки: очки сразу перестают начисляться, а
+	// накопленная часть интервала сгорает
+	holder.X, holder.Y = 900, 100
+	cp.CaptureStart = time.Now().Add(-scoreInterval)
+	updateCapturePoints()
+	if holder.Points != 1 {
+		t.Fatalf("владелец вне точки получил очки: %d", holder.Points)
+	}
+	holder.X, holder.Y = cp.X, cp.Y
+	updateCapturePoints()
+	if holder.Points != 1 {
+		t.Fatalf("вернувшийся владелец получил очки за время вне точки: %d", holder.Points)
+	}
+}
+
+func TestCapturedPointCarriesOwnerColor(t *testing.T) {
+	resetGame(t)
+	owner, _ := addTestPlayer(t, 100, 700)
+	ownPoint(&capturePoints[0], owner, 0)
+
+	state := getCapturePointsState()
+	if state[0].OwnerColor != owner.Color || state[1].OwnerColor != neutralColor {
+		t.Fatalf("цвета точек %q и %q, ожидались %q и нейтральный", state[0].OwnerColor, state[1].OwnerColor, owner.Color)
+	}
+
+	// Даже если точка ещё числится за ушедшим игроком, она окрашивается нейтрально
+	delete(players, owner.ID)
+	if color := getCapturePointsState()[0].OwnerColor; color != neutralColor {
+		t.Fatalf("точка ушедшего владельца окрашена в %q", color)
+	}
+	players[owner.ID] = owner
+	removePlayer(owner.ID, "left")
+	if color := getCapturePointsState()[0].OwnerColor; color != neutralColor || capturePoints[0].IsCaptured {
+		t.Fatalf("после удаления владельца точка окрашена в %q", color)
+	}
+}
+
+func TestFirstInKeepsCapturing(t *testing.T) {
+	resetGame(t)
+	config.ContestRule = contestRuleFirstIn
+	cp := &capturePoints[0]
+	defender, _ := addTestPlayer(t, cp.X, cp.Y)
+	attacker, _ := addTestPlayer(t, 900, 700)
+
+	updateCapturePoints()
+	cp.Occupants[defender.ID] = time.Now().Add(-time.Second)
+	cp.EnterTime = time.Now().Add(-captureTime / 2)
+	entered := cp.EnterTime
+
+	// Второй игрок вошёл позже: захват не сбрасывается и продолжается
+	attacker.X, attacker.Y = cp.X+10, cp.Y
+	updateCapturePoints()
+	if cp.CurrentCapturingPlayer != defender.ID || !cp.EnterTime.Equal(entered) {
+		t.Fatalf("захват у игрока %d с %v, ожидался игрок %d с прежним прогрессом", cp.CurrentCapturingPlayer, cp.EnterTime, defender.ID)
+	}
+
+	cp.EnterTime = time.Now().Add(-captureTime)
+	updateCapturePoints()
+	if !cp.IsCaptured || cp.CapturingPlayer != defender.ID {
+		t.Fatalf("точка захвачена=%v игроком %d, ожидался игрок %d", cp.IsCaptured, cp.CapturingPlayer, defender.ID)
+	}
+}
+
+func TestHoldCapNeutralizesPoint(t *testing.T) {
+	resetGame(t)
+	config.MaxHoldTime = Duration(time.Minute)
+	config.CapturePoints[1].MaxHoldTime = Duration(10 * time.Second)
+	loadCapturePoints()
+	owner, client := addTestPlayer(t, 900, 100)
+	ownPoint(&capturePoints[0], owner, 30*time.Second)
+	ownPoint(&capturePoints[1], owner, 10*time.Second)
+
+	// Общий предел не достигнут, а у второй точки свой, более короткий
+	updateCapturePoints()
+	if !capturePoints[0].IsCaptured || capturePoints[1].IsCaptured {
+		t.Fatalf("захвачены: первая %v, вторая %v", capturePoints[0].IsCaptured, capturePoints[1].IsCaptured)
+	}
+	msg, ok := client.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "neutralized" })
+	if !ok || msg["reason"] != "hold_cap" || msgFloat(t, msg, "point") != float64(capturePoints[1].ID) {
+		t.Fatalf("событие освобождения: %v", msg)
+	}
+
+	// Нейтральная точка больше не приносит очков
+	points := owner.Points
+	capturePoints[0].CaptureStart = time.Now().Add(-scoreInterval)
+	updateCapturePoints()
+	if owner.Points != points+streakMultiplier(30*time.Second) {
+		t.Fatalf("очков %d, ожидалось начисление только за первую точку", owner.Points)
+	}
+}
+
+func TestAFKOwnerStopsScoring(t *testing.T) {
+	resetGame(t)
+	config.AfkScoreTimeout = Duration(30 * time.Second)
+	cp := &capturePoints[0]
+	owner, client := addTestPlayer(t, cp.X, cp.Y)
+	ownPoint(cp, owner, scoreInterval)
+
+	owner.LastActivity = time.Now().Add(-time.Minute)
+	updateCapturePoints()
+	if owner.Points != 0 {
+		t.Fatalf("бездействующий владелец получил %d очков", owner.Points)
+	}
+	// Пропущенные интервалы не копятся
+	cp.CaptureStart = time.Now().Add(-3 * scoreInterval)
+	updateCapturePoints()
+	if owner.Points != 0 {
+		t.Fatalf("бездействующий владелец получил %d очков", owner.Points)
+	}
+
+	owner.LastSeen = time.Now()
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(owner.ID), "x": cp.X + 5, "y": cp.Y})
+	cp.CaptureStart = time.Now().Add(-scoreInterval)
+	updateCapturePoints()
+	if owner.Points != 1 {
+		t.Fatalf("после движения владелец получил %d очков, ожидалось 1", owner.Points)
+	}
+}
+
+func TestContestedCaptureBonusIgnoresTeammates(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	config.ContestedCaptureBonus = 5
+	cp := &capturePoints[0]
+	capturer, _ := addTestPlayer(t, cp.X, cp.Y)
+	nearby, _ := addTestPlayer(t, cp.X+cp.Radius+30, cp.Y)
+
+	// Союзник рядом с точкой не делает захват оспоренным
+	capturer.Team, nearby.Team = 1, 1
+	completeCapture(cp, capturer)
+	if !cp.IsCaptured || capturer.Points != 0 {
+		t.Fatalf("захват рядом с союзником: захвачена=%v, очков %d", cp.IsCaptured, capturer.Points)
+	}
+
+	// Противник на том же месте приносит бонус
+	neutralizePoint(cp, "test")
+	nearby.Team = 2
+	completeCapture(cp, capturer)
+	if capturer.Points != config.ContestedCaptureBonus {
+		t.Fatalf("захват рядом с противником: очков %d, ожидалось %d", capturer.Points, config.ContestedCaptureBonus)
+	}
+
+	// Без команд противник — любой другой игрок
+	neutralizePoint(cp, "test")
+	config.Teams = 0
+	capturer.Team, nearby.Team = 0, 0
+	completeCapture(cp, capturer)
+	if capturer.Points != 2*config.ContestedCaptureBonus {
+		t.Fatalf("захват без команд: очков %d, ожидалось %d", capturer.Points, 2*config.ContestedCaptureBonus)
+	}
+}
+
+// activePointIDs возвращает ID активных точек
+func activePointIDs() []int {
+	var ids []int
+	for _, cp := range capturePoints {
+		if cp.Active {
+			ids = append(ids, cp.ID)
+		}
+	}
+	return ids
+}
+
+func TestRotationChangesActivePoints(t *testing.T) {
+	resetGame(t)
+	config.ActivePoints = 1
+	config.RotationInterval = Duration(10 * time.Second)
+	loadCapturePoints()
+	player, client := addTestPlayer(t, 900, 100)
+
+	initial := activePointIDs()
+	if len(initial) != 1 {
+		t.Fatalf("активных точек %v, ожидалась одна", initial)
+	}
+	// До истечения интервала набор не меняется
+	updateCapturePoints()
+	if got := activePointIDs(); got[0] != initial[0] {
+		t.Fatalf("точки сменились раньше интервала: %v", got)
+	}
+
+	// Выбор случайный и может повториться, поэтому интервал истекает, пока
+	// набор не сменится
+	for i := 0; ; i++ {
+		if i == 20 {
+			t.Fatal("набор активных точек не меняется")
+		}
+		nextRotation = time.Now().Add(-time.Millisecond)
+		updateCapturePoints()
+		if activePointIDs()[0] != initial[0] {
+			break
+		}
+	}
+	msg, ok := client.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "deactivated" })
+	if !ok || msgFloat(t, msg, "point") != float64(initial[0]) {
+		t.Fatalf("событие выключения точки: %v", msg)
+	}
+
+	// Выключенную точку захватить нельзя
+	inactive := &capturePoints[initial[0]-1]
+	completeCapture(inactive, player)
+	if inactive.IsCaptured {
+		t.Fatal("выключенная точка захвачена")
+	}
+}
+
+func TestAwardSendsOneScoreEvent(t *testing.T) {
+	resetGame(t)
+	owner, client := addTestPlayer(t, 100, 700)
+	ownPoint(&capturePoints[1], owner, scoreInterval)
+
+	updateCapturePoints()
+	// Следующая проверка в том же интервале очков не даёт и событий не шлёт
+	updateCapturePoints()
+	scores := collectMessages(client, 200*time.Millisecond, hasType("score"))
+	if len(scores) != 1 {
+		t.Fatalf("событий счёта %d, ожидалось одно: %v", len(scores), scores)
+	}
+	if msgFloat(t, scores[0], "id") != float64(owner.ID) || msgFloat(t, scores[0], "points") != float64(owner.Points) || owner.Points != 1 {
+		t.Fatalf("событие %v при счёте %d", scores[0], owner.Points)
+	}
+}
+
+func TestCaptureRulesOnZoneBoundary(t *testing.T) {
+	resetGame(t)
+	config.PlayerRadius = 20
+	cp := &capturePoints[0]
+	player, _ := addTestPlayer(t, 0, cp.Y)
+
+	for _, tt := range []struct {
+		rule   string
+		offset float64 // Расстояние от центра игрока до края зоны, минус — внутри
+		want   bool
+	}{
+		{captureRuleCenter, -1, true},
+		{captureRuleCenter, 1, false},
+		{captureRuleOverlap, 10, true},
+		{captureRuleOverlap, 21, false},
+		{captureRuleInside, -10, false},
+		{captureRuleInside, -21, true},
+	} {
+		config.CaptureRule = tt.rule
+		player.X = cp.X + cp.Radius + tt.offset
+		if got := isPlayerInZone(player, cp); got != tt.want {
+			t.Errorf("правило %s, центр в %+v от края: на точке=%v, ожидалось %v", tt.rule, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestPushOutDetectedWithinTick(t *testing.T) {
+	resetGame(t)
+	config.PushStrength = 5000
+	cp := &capturePoints[0]
+	capturer, client := addTestPlayer(t, cp.X, cp.Y+10)
+	pusher, _ := addTestPlayer(t, cp.X, cp.Y-cp.Radius-15)
+	updateCapturePoints()
+	if cp.CurrentCapturingPlayer != capturer.ID {
+		t.Fatal("захват не начался")
+	}
+
+	// Проверка точек раз в 100 мс не вызывается: выход замечает сам тик
+	applyPush(pusher)
+	now := time.Now()
+	for i := 0; isPlayerInZone(capturer, cp); i++ {
+		if i == 100 {
+			t.Fatal("захватчик не вытолкнут из зоны")
+		}
+		now = now.Add(10 * time.Millisecond)
+		gameTick(now, 10*time.Millisecond, false)
+	}
+	if cp.PausedAt.IsZero() || cp.PausedAt.After(now) {
+		t.Fatal("выход из зоны не замечен в тот же тик")
+	}
+	msg, ok := client.next(testTimeout, func(m map[string]interface{}) bool { return m["type"] == "zone" && m["event"] == "exit" })
+	if !ok || msgFloat(t, msg, "player") != float64(capturer.ID) {
+		t.Fatalf("событие выхода из зоны: %v", msg)
+	}
+}
+
+func TestSquadPointNeedsTwoTeammates(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	cp := &capturePoints[0]
+	cp.RequiredPlayers = 2
+	first, _ := addTestPlayer(t, cp.X, cp.Y)
+	second, _ := addTestPlayer(t, 100, 700)
+	third, _ := addTestPlayer(t, 150, 700)
+	first.Team, second.Team, third.Team = 1, 1, 1
+
+	// Одного игрока мало: захват не начинается
+	updateCapturePoints()
+	if !cp.EnterTime.IsZero() {
+		t.Fatal("захват начат одним игроком")
+	}
+
+	second.X, second.Y = cp.X+10, cp.Y
+	updateCapturePoints()
+	if cp.EnterTime.IsZero() || cp.CaptureTeam != 1 {
+		t.Fatal("захват не начат двумя игроками одной команды")
+	}
+	entered := cp.EnterTime.Add(-captureTime / 2)
+	cp.EnterTime = entered
+
+	// Игрока, от имени которого идёт захват, выталкивают, но третий союзник
+	// занимает его место: прогресс принадлежит команде и не сбрасывается
+	representative := players[cp.CurrentCapturingPlayer]
+	third.X, third.Y = cp.X-10, cp.Y
+	representative.X, representative.Y = 500, 700
+	checkZoneExits(time.Now())
+	updateCapturePoints()
+	if !cp.EnterTime.Equal(entered) || cp.CurrentCapturingPlayer == representative.ID {
+		t.Fatalf("смена игрока в отряде сбросила прогресс: захват с %v, ожидалось %v", cp.EnterTime, entered)
+	}
+
+	// Оставшийся пройти захват отряд завершает
+	cp.EnterTime = cp.EnterTime.Add(-captureTime / 2)
+	updateCapturePoints()
+	if !cp.IsCaptured || cp.OwnerTeam != 1 {
+		t.Fatalf("точка захвачена=%v, команда %d", cp.IsCaptured, cp.OwnerTeam)
+	}
+}
+
+func TestSnapshotHasRelativeCaptureTimers(t *testing.T) {
+	resetGame(t)
+	capturer, _ := addTestPlayer(t, capturePoints[0].X, capturePoints[0].Y)
+	owner, _ := addTestPlayer(t, 100, 700)
+	capturePoints[0].CurrentCapturingPlayer = capturer.ID
+	capturePoints[0].EnterTime = time.Now().Add(-captureTime / 2)
+	ownPoint(&capturePoints[1], owner, 3*time.Second)
+	capturePoints[1].CaptureStart = time.Now().Add(-2 * time.Second)
+	capturer.LastPushTime = time.Now().Add(-time.Duration(config.PushCooldown) / 2)
+	grantBuff(owner, buffSpeed, 0.5, 4*time.Second, time.Now())
+
+	state := currentGameState(true)
+	data, err := json.Marshal(state)
+	releasePlayersState(state.Players)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot struct {
+		Players       []map[string]interface{} `json:"players"`
+		CapturePoints []map[string]interface{} `json:"capturePoints"`
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	var all interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		t.Fatal(err)
+	}
+
+	capturing, owned := snapshot.CapturePoints[0], snapshot.CapturePoints[1]
+	if p := msgFloat(t, capturing, "progress"); p < 0.45 || p > 0.55 {
+		t.Fatalf("прогресс захвата %v, ожидалась половина", p)
+	}
+	if held := msgFloat(t, owned, "heldFor"); held < 2.9 || held > 3.1 {
+		t.Fatalf("удерживается %v с, ожидалось 3", held)
+	}
+	if next := msgFloat(t, owned, "nextScoreIn"); math.Abs(next-(scoreInterval-2*time.Second).Seconds()) > 0.1 {
+		t.Fatalf("до очков %v с", next)
+	}
+
+	// Перезарядки и усиления игроков тоже относительные
+	byID := make(map[float64]map[string]interface{})
+	for _, player := range snapshot.Players {
+		byID[player["id"].(float64)] = player
+	}
+	pushLeft := (time.Duration(config.PushCooldown) / 2).Seconds()
+	if left := msgFloat(t, byID[float64(capturer.ID)], "pushCooldown"); math.Abs(left-pushLeft) > 0.1 {
+		t.Fatalf("до push %v с, ожидалось %v", left, pushLeft)
+	}
+	if left := msgFloat(t, byID[float64(capturer.ID)], "pullCooldown"); left != 0 {
+		t.Fatalf("до pull %v с у игрока, который его не применял", left)
+	}
+	buffs, _ := byID[float64(owner.ID)]["buffs"].([]interface{})
+	if len(buffs) != 1 {
+		t.Fatalf("усиления владельца: %v", byID[float64(owner.ID)]["buffs"])
+	}
+	if left := msgFloat(t, buffs[0].(map[string]interface{}), "expiresIn"); left < 3.9 || left > 4 {
+		t.Fatalf("усиление истекает через %v с, ожидалось 4", left)
+	}
+
+	// Абсолютных меток времени сервера в снимке нет ни у точек, ни у игроков
+	if path, s := findAbsoluteTime(all, "снимок"); path != "" {
+		t.Fatalf("поле %s с абсолютным временем %q", path, s)
+	}
+	for _, object := range append(snapshot.Players, snapshot.CapturePoints...) {
+		for _, key := range []string{"captureStart", "enterTime", "holdStart", "CaptureStart", "EnterTime", "HoldStart", "LastPushTime", "LastPullTime"} {
+			if _, ok := object[key]; ok {
+				t.Fatalf("в объекте %v есть поле %q", object["id"], key)
+			}
+		}
+	}
+}
+
+// findAbsoluteTime ищет в разобранном JSON строку с меткой времени и
+// возвращает путь к ней
+func findAbsoluteTime(v interface{}, path string) (string, string) {
+	switch v := v.(type) {
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return path, v
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			if p, s := findAbsoluteTime(value, path+"."+key); p != "" {
+				return p, s
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			if p, s := findAbsoluteTime(value, fmt.Sprintf("%s[%d]", path, i)); p != "" {
+				return p, s
+			}
+		}
+	}
+	return "", ""
+}
+
+func TestUnattendedPointDrainsToNeutral(t *testing.T) {
+	resetGame(t)
+	config.DrainTime = Duration(10 * time.Second)
+	away, _ := addTestPlayer(t, 100, 700)
+	held := &capturePoints[1]
+	holder, _ := addTestPlayer(t, held.X, held.Y)
+	drained := &capturePoints[0]
+	ownPoint(drained, away, 0)
+	ownPoint(held, holder, 0)
+
+	updateCapturePoints()
+	if !drained.IsCaptured || drained.DrainStart.IsZero() || !held.DrainStart.IsZero() {
+		t.Fatal("ослабевание не началось только у точки без владельца")
+	}
+	// Половина времени: захват ослаб, но держится
+	drained.DrainStart = time.Now().Add(-time.Duration(config.DrainTime) / 2)
+	updateCapturePoints()
+	state := getCapturePointsState()
+	if !drained.IsCaptured || state[0].Drain < 0.45 || state[0].Drain > 0.55 || state[1].Drain != 0 {
+		t.Fatalf("на полпути: захвачена=%v, ослабла на %v, удерживаемая на %v", drained.IsCaptured, state[0].Drain, state[1].Drain)
+	}
+
+	drained.DrainStart = time.Now().Add(-time.Duration(config.DrainTime))
+	updateCapturePoints()
+	if drained.IsCaptured {
+		t.Fatal("точка без владельца не стала нейтральной")
+	}
+	if !held.IsCaptured || held.CapturingPlayer != holder.ID {
+		t.Fatal("удерживаемая точка потеряна")
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Config — настройки сервера, загружаемые из JSON-файла
+type Config struct {
+	Seed int64 `json:"seed"` // Зерно генератора случайных чисел (0 — случайное)
+
+	WorldWidth      float64 `json:"worldWidth"`      // Ширина мира, игроки не выходят за его границы
+	WorldHeight     float64 `json:"worldHeight"`     // Высота мира
+	ScaleMapToWorld bool    `json:"scaleMapToWorld"` // Растянуть карту, нарисованную для мира 1000×800, до размеров мира
+
+	Mode                string               `json:"mode"`                // Режим игры: points или koth
+	Teams               int                  `json:"teams"`               // Количество команд, 0 или 1 — каждый сам за себя
+	TeamImbalance       int                  `json:"teamImbalance"`       // Допустимая разница в численности команд, при большей игроки переводятся (0 — не перебалансировать)
+	LastStandMultiplier int                  `json:"lastStandMultiplier"` // Множитель очков для команды в меньшинстве (0 или 1 — выключено)
+	LastStandRatio      float64              `json:"lastStandRatio"`      // Во сколько раз активных противников должно быть больше, чтобы команда считалась в меньшинстве
+	Map                 string               `json:"map"`                 // Встроенная карта, заменяет препятствия, точки появления и захвата
+	Obstacles           []Obstacle           `json:"obstacles"`           // Препятствия на карте
+	CapturePoints       []CapturePointConfig `json:"capturePoints"`       // Точки захвата
+	DefaultRadius       float64              `json:"defaultRadius"`       // Радиус точки захвата, если он не указан
+	MaxCapturePoints    int                  `json:"maxCapturePoints"`    // Сколько точек захвата может быть на карте (0 — без ограничения)
+	SpawnPoints         []Point              `json:"spawnPoints"`         // Точки появления игроков
+	TeamSpawns          map[int][]Point      `json:"teamSpawns"`          // Точки появления по номерам команд; команда без своих точек появляется на общих
+	PingInterval        Duration             `json:"pingInterval"`        // Интервал отправки ping игрокам
+	Workers             int                  `json:"workers"`             // Количество обработчиков входящих пакетов
+	ViewRange           float64              `json:"viewRange"`           // Радиус, в котором игроки получают локальные события
+	MetricsAddr         string               `json:"metricsAddr"`         // Адрес HTTP-сервера метрик ("" — не запускать)
+	ListenAddr          string               `json:"listenAddr"`          // UDP-адрес игрового сервера, порт 0 — любой свободный
+	AdminToken          string               `json:"adminToken"`          // Токен для команд администратора ("" — команды отключены)
+
+	// Шифрованный канал для клиентов: DTLS поверх UDP на отдельном адресе.
+	// Открытый канал ListenAddr продолжает работать, например для локальных тестов
+	DTLSListenAddr string `json:"dtlsListenAddr"` // UDP-адрес для клиентов с DTLS ("" — не включать)
+	DTLSCertFile   string `json:"dtlsCertFile"`   // Сертификат сервера в формате PEM
+	DTLSKeyFile    string `json:"dtlsKeyFile"`    // Закрытый ключ сертификата в формате PEM
+
+	MaxPlayers    int  `json:"maxPlayers"`    // Максимум игроков (0 — сколько помещается в датаграмму состояния)
+	MinPlayers    int  `json:"minPlayers"`    // Матч приостанавливается, пока игроков меньше (0 — не приостанавливать)
+	WaitingRoom   bool `json:"waitingRoom"`   // При заполненном сервере ставить новых игроков в очередь вместо отказа
+	MaxWaiting    int  `json:"maxWaiting"`    // Максимум клиентов в очереди ожидания (0 — без ограничения)
+	MaxSpectators int  `json:"maxSpectators"` // Максимум зрителей (0 — без ограничения)
+
+	AllowMultiplePerAddress bool `json:"allowMultiplePerAddress"` // Разрешить несколько игроков с одного адреса
+
+	PlayerTimeout   Duration `json:"playerTimeout"`   // Удалять игрока, если от него нет сообщений дольше
+	HeartbeatGrace  int      `json:"heartbeatGrace"`  // Сколько интервалов ping подряд игрок должен молчать сверх PlayerTimeout, чтобы быть удалённым (0 — достаточно PlayerTimeout)
+	IdleTimeout     Duration `json:"idleTimeout"`     // Выкидывать игрока без перемещений и действий дольше (0 — не выкидывать)
+	AfkScoreTimeout Duration `json:"afkScoreTimeout"` // Не начислять очки за точки игроку без перемещений и действий дольше (0 — начислять)
+
+	MaxWriteFailures int `json:"maxWriteFailures"` // Удалять игрока после стольких неудачных отправок подряд (0 — не удалять)
+
+	// Ограничения частоты сообщений одного игрока
+	MoveRateLimit   RateLimit `json:"moveRateLimit"`
+	ActionRateLimit RateLimit `json:"actionRateLimit"`
+	ChatRateLimit   RateLimit `json:"chatRateLimit"`
+
+	TickInterval     Duration `json:"tickInterval"`     // Интервал рассылки состояния игры
+	KeyframeInterval Duration `json:"keyframeInterval"` // Интервал рассылки полного состояния (ключевого кадра)
+	MaxSpeed         float64  `json:"maxSpeed"`         // Максимальная скорость игрока, ед./с (0 — не проверять)
+	InputBufferSize  int      `json:"inputBufferSize"`  // Сколько позиций игрока сглаживается по тикам (0 — применять сразу)
+	PushCooldown     Duration `json:"pushCooldown"`     // Перезарядка действия "push"
+	PullCooldown     Duration `json:"pullCooldown"`     // Перезарядка действия "pull"
+
+	PushRange    float64 `json:"pushRange"`    // Дальность действия "push"
+	PushStrength float64 `json:"pushStrength"` // Сила отталкивания
+	PushRecoil   float64 `json:"pushRecoil"`   // Доля силы "push", отбрасывающая толкнувшего назад (0 — без отдачи)
+	PushArc      float64 `json:"pushArc"`      // Ширина сектора "push" перед игроком по Facing, радианы (0 — во все стороны)
+	PullRange    float64 `json:"pullRange"`    // Дальность действия "pull"
+	PullStrength float64 `json:"pullStrength"` // Сила притяжения
+
+	KnockbackDecay       float64  `json:"knockbackDecay"`       // Доля скорости отброса, сохраняемая на каждом шаге (0..1], 1 — без трения
+	KnockbackDuration    Duration `json:"knockbackDuration"`    // За сколько отброшенный игрок проходит всё смещение
+	MaxActiveKnockbacks  int      `json:"maxActiveKnockbacks"`  // Сколько игроков одновременно могут быть отброшены (0 — без ограничения)
+	MaxKnockbackDistance float64  `json:"maxKnockbackDistance"` // Наибольшее смещение от одного отброса (0 — без ограничения)
+
+	Buffs map[string]BuffRule       `json:"buffs"` // Правила наложения усилений по типам
+	Skins map[string]SkinAttributes `json:"skins"` // Игровые параметры скинов, неизвестные скины — без изменений
+
+	SpawnProtection        Duration `json:"spawnProtection"`        // Неуязвимость после появления, снимается движением или атакующим действием
+	AttackBreaksProtection bool     `json:"attackBreaksProtection"` // Атакующее действие (push, pull, swap, freeze) снимает защиту после появления
+
+	MaxHP      int     `json:"maxHP"`      // Здоровье игрока при появлении
+	WallDamage float64 `json:"wallDamage"` // Урон за единицу смещения, погашенного препятствием (0 — без урона)
+
+	SwapRange    float64  `json:"swapRange"`    // Дальность действия "swap"
+	SwapCooldown Duration `json:"swapCooldown"` // Перезарядка действия "swap"
+
+	FreezeRadius   float64  `json:"freezeRadius"`   // Радиус действия "freeze"
+	FreezeDuration Duration `json:"freezeDuration"` // Длительность заморозки
+	FreezeCooldown Duration `json:"freezeCooldown"` // Перезарядка действия "freeze"
+
+	DashDistance float64  `json:"dashDistance"` // На сколько рывок "dash" переносит игрока по направлению Facing
+	DashCooldown Duration `json:"dashCooldown"` // Перезарядка действия "dash"
+
+	GlobalCooldown   Duration `json:"globalCooldown"`   // Общая перезарядка всех действий после любого из них (0 — нет)
+	CooldownsInState string   `json:"cooldownsInState"` // Оставшиеся перезарядки для клиентов: none, own — только свои, all — у всех в снимке
+
+	Emotes        []string `json:"emotes"`        // Разрешённые эмоции
+	EmoteCooldown Duration `json:"emoteCooldown"` // Минимальный интервал между эмоциями игрока
+
+	MatchDuration  Duration `json:"matchDuration"`  // Длительность матча (0 — без ограничения)
+	PostMatchHold  Duration `json:"postMatchHold"`  // Сколько показываются итоги перед автоматическим началом нового матча (0 — новый матч не начинается)
+	SuddenDeath    bool     `json:"suddenDeath"`    // Овертайм при ничьей по окончании времени
+	ScoreToWin     int      `json:"scoreToWin"`     // Очки для досрочной победы (0 — без ограничения)
+	StartingPoints int      `json:"startingPoints"` // Очки игрока при подключении; сохраняются при возрождении
+
+	LeaderboardFile string `json:"leaderboardFile"` // Файл таблицы лидеров ("" — хранить только в памяти)
+	LeaderboardSize int    `json:"leaderboardSize"` // Количество записей в ответе на запрос таблицы
+
+	AuditFile string `json:"auditFile"` // Журнал аудита захватов и очков в формате JSON lines ("" — не вести)
+
+	MaxOwnedPoints int      `json:"maxOwnedPoints"` // Сколько точек игрок может удерживать одновременно (0 — без ограничения)
+	ContestRule    string   `json:"contestRule"`    // Несколько игроков в зоне: contest — захват сбрасывается, first-in — продолжает вошедший первым
+	CaptureRule    string   `json:"captureRule"`    // Когда игрок стоит на точке: center — центр в зоне, overlap — касается зоны, inside — целиком внутри
+	PlayerRadius   float64  `json:"playerRadius"`   // Радиус игрока для правил overlap и inside
+	MaxHoldTime    Duration `json:"maxHoldTime"`    // Точка становится нейтральной, если один владелец держит её дольше (0 — без ограничения)
+	DrainTime      Duration `json:"drainTime"`      // За сколько точка без владельца в зоне становится нейтральной (0 — не становится)
+	CaptureGrace   Duration `json:"captureGrace"`   // Сколько замерший прогресс ждёт вышедшего из зоны захватчика (0 — сбрасывается сразу)
+
+	// Смена целей: активны только ActivePoints случайных точек, набор
+	// меняется каждые RotationInterval
+	ActivePoints     int      `json:"activePoints"`     // 0 — все точки активны
+	RotationInterval Duration `json:"rotationInterval"` // 0 — набор не меняется
+
+	// Бонус за захват, завершённый, когда рядом с точкой есть противник
+	ContestedCaptureBonus  int     `json:"contestedCaptureBonus"`  // Дополнительные очки (0 — без бонуса)
+	ContestedCaptureRadius float64 `json:"contestedCaptureRadius"` // Радиус от центра точки, в котором ищется противник
+
+	AssistWindow Duration `json:"assistWindow"` // Помощь в захвате засчитывается, если игрок был в зоне не раньше чем за это время
+
+	// Бонус за непрерывное удержание точки: множитель очков растёт на 1
+	// за каждые StreakStep удержания, но не выше MaxStreakMultiplier
+	StreakStep          Duration `json:"streakStep"`
+	MaxStreakMultiplier int      `json:"maxStreakMultiplier"`
+}
+
+// CapturePointConfig — описание точки захвата в конфигурации карты
+type CapturePointConfig struct {
+	X               float64  `json:"x"`
+	Y               float64  `json:"y"`
+	Radius          float64  `json:"radius"`          // Если не указан, берётся DefaultRadius
+	ScoreWeight     int      `json:"scoreWeight"`     // Множитель очков за удержание (0 — как 1)
+	MaxHoldTime     Duration `json:"maxHoldTime"`     // Переопределяет MaxHoldTime для этой точки
+	RequiredPlayers int      `json:"requiredPlayers"` // Сколько игроков одной команды должно стоять в зоне для захвата (0 или 1 — один)
+
+	radiusSet bool // Радиус явно указан в файле конфигурации
+}
+
+// UnmarshalJSON запоминает, указан ли радиус, чтобы отличить пропущенный
+// радиус от явного нуля
+func (pc *CapturePointConfig) UnmarshalJSON(data []byte) error {
+	type plain CapturePointConfig
+	var aux struct {
+		plain
+		Radius *float64 `json:"radius"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*pc = CapturePointConfig(aux.plain)
+	if aux.Radius != nil {
+		pc.Radius = *aux.Radius
+		pc.radiusSet = true
+	}
+	return nil
+}
+
+// Duration — time.Duration, записываемый в JSON строкой вида "1.5s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+var (
+	config     = defaultConfig()
+	configPath string // Файл, из которого загружена конфигурация
+	mapFlag    string // Карта из флага -map, заменяет настройку Map из файла
+
+	// Геометрия карты из перезагруженной конфигурации, применяется со следующего матча
+	pendingMap *Config
+)
+
+// defaultConfig возвращает настройки по умолчанию
+func defaultConfig() Config {
+	return Config{
+		WorldWidth:    referenceWorldWidth,
+		WorldHeight:   referenceWorldHeight,
+		DefaultRadius: 50,
+		CapturePoints: []CapturePointConfig{
+			{X: 300, Y: 200, Radius: 50},
+			{X: 800, Y: 600, Radius: 50},
+			{X: 550, Y: 400, Radius: 50},
+		},
+		SpawnPoints:            []Point{{X: 400, Y: 400}},
+		PingInterval:           Duration(time.Second),
+		ListenAddr:             "0.0.0.0:8080",
+		Workers:                4,
+		ViewRange:              600,
+		MaxSpectators:          16,
+		MaxWaiting:             16,
+		PlayerTimeout:          Duration(10 * time.Second),
+		HeartbeatGrace:         3,
+		IdleTimeout:            Duration(2 * time.Minute),
+		MaxWriteFailures:       100,
+		CaptureGrace:           Duration(time.Second),
+		PushRange:              100,
+		PushStrength:           1000,
+		PullRange:              100,
+		PullStrength:           1000,
+		KnockbackDecay:         0.7,
+		KnockbackDuration:      Duration(defaultKnockbackDuration),
+		MaxActiveKnockbacks:    64,
+		TickInterval:           Duration(10 * time.Millisecond),
+		KeyframeInterval:       Duration(2 * time.Second),
+		PushCooldown:           Duration(2 * time.Second),
+		PullCooldown:           Duration(2 * time.Second),
+		SpawnProtection:        Duration(3 * time.Second),
+		AttackBreaksProtection: true,
+		Buffs: map[string]BuffRule{
+			buffShield:   {Stacking: stackExtend},
+			buffSpeed:    {Stacking: stackCapped, MaxMagnitude: 1},
+			buffCooldown: {Stacking: stackCapped, MaxMagnitude: 0.5},
+		},
+		MaxHP:                  100,
+		MaxCapturePoints:       32,
+		SwapRange:              150,
+		SwapCooldown:           Duration(5 * time.Second),
+		FreezeRadius:           120,
+		FreezeDuration:         Duration(1500 * time.Millisecond),
+		FreezeCooldown:         Duration(8 * time.Second),
+		DashDistance:           150,
+		DashCooldown:           Duration(3 * time.Second),
+		CooldownsInState:       cooldownsOwn,
+		Emotes:                 []string{"gg", "hi", "gl", "wow", "oops"},
+		EmoteCooldown:          Duration(time.Second),
+		SuddenDeath:            true,
+		PostMatchHold:          Duration(10 * time.Second),
+		LeaderboardSize:        10,
+		StreakStep:             Duration(15 * time.Second),
+		AssistWindow:           Duration(5 * time.Second),
+		ContestedCaptureRadius: 150,
+		LastStandRatio:         2,
+		CaptureRule:            captureRuleCenter,
+		PlayerRadius:           16,
+		MaxStreakMultiplier:    3,
+	}
+}
+
+// loadConfig читает конфигурацию из файла поверх значений по умолчанию и
+// проверяет её. Без файла (пустой path) проверяются значения по умолчанию.
+// Карта из флага -map подставляется до проверок, как и карта из файла
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if mapFlag != "" {
+		cfg.Map = mapFlag
+	}
+	if cfg.Map != "" {
+		if err := applyMapPreset(&cfg, cfg.Map); err != nil {
+			return cfg, err
+		}
+	}
+
+	// Точкам без радиуса подставляется радиус по умолчанию
+	for i := range cfg.CapturePoints {
+		if pc := &cfg.CapturePoints[i]; !pc.radiusSet && pc.Radius == 0 {
+			pc.Radius = cfg.DefaultRadius
+		}
+	}
+	if cfg.MaxCapturePoints > 0 && len(cfg.CapturePoints) > cfg.MaxCapturePoints {
+		return cfg, fmt.Errorf("на карте %d точек захвата, допускается не больше %d", len(cfg.CapturePoints), cfg.MaxCapturePoints)
+	}
+	if err := validateCapturePoints(cfg.CapturePoints); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxPlayers < 0 || cfg.MaxPlayers > maxStatePlayers {
+		return cfg, fmt.Errorf("maxPlayers должен быть от 0 до %d: состояние игры отправляется одной датаграммой", maxStatePlayers)
+	}
+	if cfg.DTLSListenAddr != "" && (cfg.DTLSCertFile == "" || cfg.DTLSKeyFile == "") {
+		return cfg, fmt.Errorf("для dtlsListenAddr нужны dtlsCertFile и dtlsKeyFile")
+	}
+	if cfg.KnockbackDuration <= 0 {
+		return cfg, fmt.Errorf("knockbackDuration должна быть положительной")
+	}
+	if cfg.DashDistance < 0 {
+		return cfg, fmt.Errorf("dashDistance не может быть отрицательной")
+	}
+	if cfg.PushArc < 0 || cfg.PushArc > 2*math.Pi {
+		return cfg, fmt.Errorf("pushArc должна быть от 0 до 2π")
+	}
+	if cfg.ScaleMapToWorld {
+		scaleMapToWorld(&cfg)
+	}
+	if err := validateWorld(cfg); err != nil {
+		return cfg, err
+	}
+	if err := validateGeometry(cfg.Obstacles, cfg.SpawnPoints, cfg.CapturePoints); err != nil {
+		return cfg, err
+	}
+	for team, spawns := range cfg.TeamSpawns {
+		if err := validateGeometry(cfg.Obstacles, spawns, cfg.CapturePoints); err != nil {
+			return cfg, fmt.Errorf("команда %d: %w", team, err)
+		}
+	}
+	warnOverlappingPoints(cfg.CapturePoints)
+	return cfg, nil
+}
+
+// watchReloadSignal перечитывает конфигурацию при получении SIGHUP
+func watchReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		reloadConfig()
+	}
+}
+
+// reloadConfig перечитывает файл конфигурации и подменяет настраиваемые
+// параметры без отключения игроков и сброса очков
+func reloadConfig() {
+	if configPath == "" {
+		log.Println("Перезагрузка конфигурации пропущена: сервер запущен без файла")
+		return
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		log.Println("Ошибка при перезагрузке конфигурации:", err)
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Карта меняется только в начале следующего матча
+	next := cfg
+	pendingMap = &next
+	cfg.Map = config.Map
+	cfg.WorldWidth = config.WorldWidth
+	cfg.WorldHeight = config.WorldHeight
+	cfg.Obstacles = config.Obstacles
+	cfg.CapturePoints = config.CapturePoints
+	cfg.SpawnPoints = config.SpawnPoints
+	cfg.TeamSpawns = config.TeamSpawns
+
+	// Эти параметры используются только при запуске
+	cfg.Seed = config.Seed
+	cfg.Workers = config.Workers
+	cfg.ListenAddr = config.ListenAddr
+	cfg.DTLSListenAddr = config.DTLSListenAddr
+	cfg.DTLSCertFile = config.DTLSCertFile
+	cfg.DTLSKeyFile = config.DTLSKeyFile
+	cfg.AuditFile = config.AuditFile
+
+	config = cfg
+	log.Println("Конфигурация перезагружена")
+}
+
+// applyPendingMap применяет отложенную геометрию карты, вызывается под mutex
+func applyPendingMap() {
+	if pendingMap == nil {
+		return
+	}
+	config.Map = pendingMap.Map
+	config.WorldWidth = pendingMap.WorldWidth
+	config.WorldHeight = pendingMap.WorldHeight
+	config.Obstacles = pendingMap.Obstacles
+	config.CapturePoints = pendingMap.CapturePoints
+	config.SpawnPoints = pendingMap.SpawnPoints
+	config.TeamSpawns = pendingMap.TeamSpawns
+	pendingMap = nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadChangesCooldowns(t *testing.T) {
+	resetGame(t)
+	configPath = writeConfig(t, `{"pushCooldown": "10s"}`)
+	player, _ := addTestPlayer(t, 400, 400)
+	lastPush := time.Now().Add(-3 * time.Second)
+	player.LastPushTime = lastPush
+
+	// Прошло больше перезарядки по умолчанию, но меньше новой
+	reloadConfig()
+	handleAction(player, "push", nil)
+	if !player.LastPushTime.Equal(lastPush) {
+		t.Fatal("толчок прошёл при перезарядке 10s из перезагруженной конфигурации")
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"pushCooldown": "1s"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	handleAction(player, "push", nil)
+	if player.LastPushTime.Equal(lastPush) {
+		t.Fatal("толчок не прошёл после уменьшения перезарядки")
+	}
+	if _, ok := players[player.ID]; !ok {
+		t.Fatal("игрок отключён при перезагрузке")
+	}
+}
+
+func TestDefaultRadiusApplied(t *testing.T) {
+	resetGame(t)
+	cfg, err := loadConfig(writeConfig(t, `{"capturePoints": [{"x": 300, "y": 200}, {"x": 800, "y": 600, "radius": 80}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CapturePoints[0].Radius != 50 || cfg.CapturePoints[1].Radius != 80 {
+		t.Fatalf("радиусы %v и %v, ожидались 50 и 80", cfg.CapturePoints[0].Radius, cfg.CapturePoints[1].Radius)
+	}
+
+	cfg, err = loadConfig(writeConfig(t, `{"defaultRadius": 70, "capturePoints": [{"x": 300, "y": 200}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CapturePoints[0].Radius != 70 {
+		t.Fatalf("радиус %v, ожидался заданный по умолчанию 70", cfg.CapturePoints[0].Radius)
+	}
+}
+
+func TestNonPositiveRadiusRejected(t *testing.T) {
+	resetGame(t)
+	for _, data := range []string{
+		`{"capturePoints": [{"x": 300, "y": 200, "radius": 0}]}`,
+		`{"capturePoints": [{"x": 300, "y": 200, "radius": -10}]}`,
+		`{"defaultRadius": 0, "capturePoints": [{"x": 300, "y": 200}]}`,
+		`{"defaultRadius": -5, "capturePoints": [{"x": 300, "y": 200}]}`,
+	} {
+		if _, err := loadConfig(writeConfig(t, data)); err == nil {
+			t.Errorf("конфигурация принята: %s", data)
+		}
+	}
+}
+
+func TestTooManyCapturePointsRejected(t *testing.T) {
+	resetGame(t)
+	points := `[{"x": 100, "y": 100}, {"x": 300, "y": 100}, {"x": 500, "y": 100}]`
+	_, err := loadConfig(writeConfig(t, `{"maxCapturePoints": 2, "capturePoints": `+points+`}`))
+	if err == nil || !strings.Contains(err.Error(), "не больше 2") {
+		t.Fatalf("ошибка %v, ожидался отказ из-за числа точек", err)
+	}
+	if _, err := loadConfig(writeConfig(t, `{"maxCapturePoints": 3, "capturePoints": `+points+`}`)); err != nil {
+		t.Fatalf("точки в пределах ограничения отклонены: %v", err)
+	}
+}
+
+func TestMaxPlayersLimitedByDatagram(t *testing.T) {
+	resetGame(t)
+	if _, err := loadConfig(writeConfig(t, fmt.Sprintf(`{"maxPlayers": %d}`, maxStatePlayers))); err != nil {
+		t.Fatalf("предельное число игроков отклонено: %v", err)
+	}
+	if _, err := loadConfig(writeConfig(t, fmt.Sprintf(`{"maxPlayers": %d}`, maxStatePlayers+1))); err == nil {
+		t.Fatal("принято больше игроков, чем помещается в датаграмму состояния")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+)
+
+// Какие оставшиеся перезарядки попадают к клиентам
+const (
+	cooldownsNone = "none" // Не отправляются
+	cooldownsOwn  = "own"  // Каждый игрок получает только свои отдельным сообщением
+	cooldownsAll  = "all"  // В снимке состояния у всех игроков
+)
+
+// remainingCooldowns возвращает, сколько миллисекунд осталось до готовности
+// каждого действия с учётом усилений и общей перезарядки GlobalCooldown.
+// Клиенту не нужно отсчитывать время самому, поэтому потеря пакетов не
+// сбивает индикаторы. Вызывается под mutex
+func remainingCooldowns(player *Player, now time.Time) map[string]int64 {
+	return map[string]int64{
+		"push":   cooldownLeft(player, player.LastPushTime, config.PushCooldown, now).Milliseconds(),
+		"pull":   cooldownLeft(player, player.LastPullTime, config.PullCooldown, now).Milliseconds(),
+		"swap":   cooldownLeft(player, player.LastSwapTime, config.SwapCooldown, now).Milliseconds(),
+		"freeze": cooldownLeft(player, player.LastFreezeTime, config.FreezeCooldown, now).Milliseconds(),
+		"dash":   cooldownLeft(player, player.LastDashTime, config.DashCooldown, now).Milliseconds(),
+	}
+}
+
+// cooldownLeft возвращает, сколько ещё ждать действия, выполненного в last,
+// с учётом общей перезарядки. Вызывается под mutex
+func cooldownLeft(player *Player, last time.Time, cooldown Duration, now time.Time) time.Duration {
+	global := time.Duration(config.GlobalCooldown) - now.Sub(player.LastActionTime)
+	return max(actionCooldown(player, cooldown, now)-now.Sub(last), global, 0)
+}
+
+// sendCooldowns отправляет игроку его перезарядки, пока хотя бы одно действие
+// не готово, и последний раз — когда все стали готовы. Вызывается под mutex
+// из игрового тика
+func sendCooldowns(player *Player, now time.Time) {
+	cooldowns := remainingCooldowns(player, now)
+	pending := false
+	for _, ms := range cooldowns {
+		if ms > 0 {
+			pending = true
+			break
+		}
+	}
+	if !pending && !player.CooldownsPending {
+		return
+	}
+	player.CooldownsPending = pending
+	sendToPlayer(player.ID, map[string]interface{}{
+		"type":      "cooldowns",
+		"cooldowns": cooldowns,
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCooldownsCountDownToZero(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	addTestPlayer(t, 450, 400)
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "action": "push"})
+	pushed := player.LastPushTime
+	cooldown := time.Duration(config.PushCooldown)
+	last := cooldown.Milliseconds() + 1
+	for step := time.Duration(0); step <= 4; step++ {
+		gameTick(pushed.Add(step*cooldown/4), 10*time.Millisecond, false)
+		cooldowns, _ := client.recv("cooldowns")["cooldowns"].(map[string]interface{})
+		ms := int64(msgFloat(t, cooldowns, "push"))
+		if step < 4 && (ms <= 0 || ms >= last) || step == 4 && ms != 0 {
+			t.Fatalf("через %v после push осталось %d мс, до этого %d", step*cooldown/4, ms, last)
+		}
+		last = ms
+	}
+	// Когда все действия готовы, сообщения больше не отправляются
+	gameTick(pushed.Add(cooldown*5/4), 10*time.Millisecond, false)
+	client.expectNone(100*time.Millisecond, hasType("cooldowns"))
+}
+
+func TestCooldownsInSnapshotForAll(t *testing.T) {
+	resetGame(t)
+	config.CooldownsInState = cooldownsAll
+	player, _ := addTestPlayer(t, 400, 400)
+	cooldown := time.Duration(config.PushCooldown)
+
+	last := cooldown.Milliseconds() + 1
+	for _, ago := range []time.Duration{0, cooldown / 2, cooldown} {
+		player.LastPushTime = time.Now().Add(-ago)
+		state := getPlayersState()
+		ms := state[0].Cooldowns["push"]
+		releasePlayersState(state)
+		if ms >= last || ago == cooldown && ms != 0 {
+			t.Fatalf("через %v после push в снимке %d мс, до этого %d", ago, ms, last)
+		}
+		last = ms
+	}
+}
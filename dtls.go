@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/dtls/v2/pkg/protocol"
+	"github.com/pion/dtls/v2/pkg/protocol/recordlayer"
+	"github.com/pion/transport/v2/udp"
+)
+
+// Сколько ждать завершения DTLS-рукопожатия с клиентом
+const dtlsHandshakeTimeout = 5 * time.Second
+
+var (
+	// Сокет для клиентов с DTLS, nil — шифрование не включено. Accept отдаёт
+	// ещё не зашифрованные соединения, рукопожатие с каждым идёт отдельно
+	dtlsListener net.Listener
+	dtlsConfig   *dtls.Config
+
+	// DTLS-сессии по адресу клиента. writeTo отправляет через сессию, если
+	// она есть, иначе — открытым текстом через conn
+	dtlsSessions sync.Map // string -> net.Conn
+	// Соединения, рукопожатие с которыми ещё идёт, по адресу клиента
+	dtlsHandshakes sync.Map // string -> net.Conn
+
+	// Закрывается, когда acceptDTLS перестаёт принимать клиентов
+	dtlsAcceptDone chan struct{}
+	// Горутины рукопожатий и чтения DTLS-сессий
+	dtlsReaders sync.WaitGroup
+)
+
+// listenDTLS открывает сокет addr для клиентов с DTLS с сертификатом из
+// certFile и ключом из keyFile. Открытый канал conn продолжает работать
+func listenDTLS(addr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	// Новое соединение открывает только пакет рукопожатия DTLS
+	lc := udp.ListenConfig{
+		AcceptFilter: func(packet []byte) bool {
+			records, err := recordlayer.UnpackDatagram(packet)
+			if err != nil || len(records) == 0 {
+				return false
+			}
+			var h recordlayer.Header
+			return h.Unmarshal(records[0]) == nil && h.ContentType == protocol.ContentTypeHandshake
+		},
+	}
+	dtlsListener, err = lc.Listen("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	dtlsConfig = &dtls.Config{
+		Certificates:         []tls.Certificate{cert},
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	}
+	dtlsAcceptDone = make(chan struct{})
+	log.Printf("Сервер слушает DTLS на %s", dtlsListener.Addr())
+	return nil
+}
+
+// acceptDTLS принимает DTLS-клиентов, пока сокет не закрыт. Рукопожатие с
+// каждым клиентом идёт в своей горутине, поэтому зависший клиент не задерживает
+// остальных. Пакеты каждой сессии попадают в ту же очередь packets, что и
+// открытые, с адресом клиента и отметкой о шифровании
+func acceptDTLS(packets chan<- packet) {
+	defer close(dtlsAcceptDone)
+	for {
+		raw, err := dtlsListener.Accept()
+		if errors.Is(err, udp.ErrClosedListener) || errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Println("Ошибка приёма DTLS-клиента:", err)
+			continue
+		}
+		addr, ok := raw.RemoteAddr().(*net.UDPAddr)
+		if !ok {
+			raw.Close()
+			continue
+		}
+		dtlsReaders.Add(1)
+		go handshakeDTLS(raw, addr, packets)
+	}
+}
+
+// handshakeDTLS выполняет рукопожатие с клиентом не дольше
+// dtlsHandshakeTimeout и читает установленную сессию
+func handshakeDTLS(raw net.Conn, addr *net.UDPAddr, packets chan<- packet) {
+	key := addr.String()
+	dtlsHandshakes.Store(key, raw)
+	ctx, cancel := context.WithTimeout(context.Background(), dtlsHandshakeTimeout)
+	session, err := dtls.ServerWithContext(ctx, raw, dtlsConfig)
+	cancel()
+	if err != nil {
+		dtlsHandshakes.Delete(key)
+		raw.Close()
+		dtlsReaders.Done()
+		log.Printf("Ошибка DTLS-рукопожатия с %s: %v", addr, err)
+		return
+	}
+	// Сессия попадает в dtlsSessions раньше, чем уходит из рукопожатий, чтобы
+	// stopDTLS нашёл её хотя бы в одном из списков
+	dtlsSessions.Store(key, session)
+	dtlsHandshakes.Delete(key)
+	select {
+	case <-dtlsAcceptDone:
+		// Сервер останавливается
+		session.Close()
+	default:
+	}
+	logDebug("DTLS-сессия с %s установлена", addr)
+	readDTLS(session, addr, packets)
+}
+
+// readDTLS читает расшифрованные пакеты сессии, пока она не закрыта
+func readDTLS(session net.Conn, addr *net.UDPAddr, packets chan<- packet) {
+	defer dtlsReaders.Done()
+	defer dtlsSessions.Delete(addr.String())
+	defer session.Close()
+
+	for {
+		buf := packetPool.Get().(*[]byte)
+		n, err := session.Read(*buf)
+		if err != nil {
+			packetPool.Put(buf)
+			logDebug("DTLS-сессия с %s закрыта: %v", addr, err)
+			return
+		}
+		enqueuePacket(packets, addr, buf, n, true)
+	}
+}
+
+// hasDTLSSession проверяет, есть ли у адреса DTLS-сессия
+func hasDTLSSession(addr *net.UDPAddr) bool {
+	_, ok := dtlsSessions.Load(addr.String())
+	return ok
+}
+
+// plaintextAllowed проверяет, можно ли принять открытый пакет. Адрес с
+// DTLS-сессией и игрок, подключившийся по DTLS, принимают только
+// зашифрованные пакеты: иначе подделавший адрес отправитель выдал бы себя за них
+func plaintextAllowed(addr *net.UDPAddr, msg map[string]interface{}) bool {
+	if hasDTLSSession(addr) {
+		return false
+	}
+	id, ok := msg["id"].(float64)
+	if !ok {
+		return true
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	player, ok := players[int(id)]
+	return !ok || !player.DTLS
+}
+
+// closeDTLSSession закрывает DTLS-сессию ушедшего клиента, если она есть
+func closeDTLSSession(key string) {
+	if session, ok := dtlsSessions.Load(key); ok {
+		session.(net.Conn).Close()
+	}
+}
+
+// stopDTLS закрывает DTLS-сокет и все сессии и ждёт, пока их читатели
+// завершатся, чтобы после этого можно было закрыть очередь пакетов
+func stopDTLS() {
+	if dtlsListener == nil {
+		return
+	}
+	dtlsListener.Close()
+	<-dtlsAcceptDone
+	dtlsHandshakes.Range(func(_, raw interface{}) bool {
+		raw.(net.Conn).Close()
+		return true
+	})
+	dtlsSessions.Range(func(key, _ interface{}) bool {
+		closeDTLSSession(key.(string))
+		return true
+	})
+	dtlsReaders.Wait()
+	dtlsListener = nil
+}
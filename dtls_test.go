@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/dtls/v2/pkg/crypto/selfsign"
+)
+
+// writeSelfSignedCert записывает самоподписанный сертификат и ключ в PEM-файлы
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	cert, err := selfsign.GenerateSelfSigned()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// readDTLSMessage ждёт JSON-сообщение из DTLS-сессии, для которого match возвращает true
+func readDTLSMessage(t *testing.T, session net.Conn, match func(map[string]interface{}) bool) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	buf := make([]byte, 64*1024)
+	for {
+		session.SetReadDeadline(deadline)
+		n, err := session.Read(buf)
+		if err != nil {
+			t.Fatalf("не дождались сообщения по DTLS: %v", err)
+		}
+		var msg map[string]interface{}
+		if json.Unmarshal(buf[:n], &msg) == nil && match(msg) {
+			return msg
+		}
+	}
+}
+
+func TestDTLSJoinReceivesState(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	startTestServer(t, func(cfg *Config) {
+		cfg.DTLSListenAddr = "127.0.0.1:0"
+		cfg.DTLSCertFile = certFile
+		cfg.DTLSKeyFile = keyFile
+	})
+
+	session, err := dtls.Dial("udp", dtlsListener.Addr().(*net.UDPAddr), &dtls.Config{
+		InsecureSkipVerify:   true,
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	send := func(msg map[string]interface{}) {
+		data, _ := json.Marshal(msg)
+		if _, err := session.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(map[string]interface{}{"type": "join", "protocolVersion": protocolVersion, "name": "secure"})
+	joined := readDTLSMessage(t, session, hasType("joined"))
+	id := msgFloat(t, joined, "id")
+
+	// Перемещение по зашифрованному каналу отражается в рассылке состояния
+	send(map[string]interface{}{"id": id, "x": 430.0, "y": 420.0, "seq": 1})
+	readDTLSMessage(t, session, func(m map[string]interface{}) bool {
+		list, _ := m["players"].([]interface{})
+		for _, item := range list {
+			if p, _ := item.(map[string]interface{}); p["id"] == id {
+				return p["x"] == 430.0 && p["y"] == 420.0
+			}
+		}
+		return false
+	})
+}
+
+func TestDTLSRequiresCertificate(t *testing.T) {
+	if _, err := loadConfig(writeConfig(t, `{"dtlsListenAddr": "127.0.0.1:0"}`)); err == nil {
+		t.Fatal("DTLS без сертификата принят")
+	}
+}
+
+// dialDTLS подключается к DTLS-сокету сервера и проходит рукопожатие за testTimeout
+func dialDTLS(t *testing.T) *dtls.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	session, err := dtls.DialWithContext(ctx, "udp", dtlsListener.Addr().(*net.UDPAddr), &dtls.Config{
+		InsecureSkipVerify:   true,
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+// startDTLSServer запускает сервер с DTLS-сокетом на свободном порту
+func startDTLSServer(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	startTestServer(t, func(cfg *Config) {
+		cfg.DTLSListenAddr = "127.0.0.1:0"
+		cfg.DTLSCertFile = certFile
+		cfg.DTLSKeyFile = keyFile
+	})
+}
+
+func TestDTLSRejectsSpoofedPlaintext(t *testing.T) {
+	startDTLSServer(t)
+	session := dialDTLS(t)
+	data, _ := json.Marshal(map[string]interface{}{"type": "join", "protocolVersion": protocolVersion, "name": "secure"})
+	if _, err := session.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	id := int(msgFloat(t, readDTLSMessage(t, session, hasType("joined")), "id"))
+
+	mutex.Lock()
+	addr := clientAddrs[id]
+	x, y := players[id].X, players[id].Y
+	mutex.Unlock()
+
+	// Открытые пакеты с адреса DTLS-клиента не управляют его игроком и не
+	// регистрируют второго
+	move, _ := json.Marshal(map[string]interface{}{"id": id, "x": x + 5, "y": y, "seq": 1})
+	handlePacket(addr, move, false)
+	handlePacket(addr, data, false)
+	mutex.Lock()
+	moved := players[id].X != x || players[id].Y != y
+	count := len(players)
+	mutex.Unlock()
+	if moved || count != 1 {
+		t.Fatalf("открытый пакет принят: игрок сдвинут=%v, игроков %d", moved, count)
+	}
+
+	// Тот же пакет по DTLS принимается
+	handlePacket(addr, move, true)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if players[id].X != x+5 {
+		t.Fatal("пакет из DTLS-сессии отклонён")
+	}
+}
+
+func TestDTLSStalledHandshakeDoesNotBlock(t *testing.T) {
+	startDTLSServer(t)
+
+	// Клиент начинает рукопожатие одной записью и замолкает
+	stalled := newTestClient(t)
+	record := []byte{22, 0xfe, 0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1}
+	if _, err := stalled.conn.WriteToUDP(record, dtlsListener.Addr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := dtlsHandshakes.Load(stalled.addr().String()); !ok {
+		t.Fatal("рукопожатие с замолкшим клиентом не началось")
+	}
+
+	start := time.Now()
+	dialDTLS(t)
+	if elapsed := time.Since(start); elapsed >= dtlsHandshakeTimeout {
+		t.Fatalf("рукопожатие ждало зависшего клиента %v", elapsed)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"time"
+)
+
+// broadcastEvent рассылает событие всем игрокам, вызывается под mutex
+func broadcastEvent(msg map[string]interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		recordMarshalError("события", err)
+		return
+	}
+	for id := range players {
+		if addr, ok := clientAddrs[id]; ok {
+			writeUDP(addr, data)
+		}
+	}
+}
+
+// broadcastNearby рассылает событие игрокам в радиусе radius от точки (x, y),
+// вызывается под mutex
+func broadcastNearby(x, y, radius float64, msg map[string]interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		recordMarshalError("события", err)
+		return
+	}
+	for id, p := range players {
+		if math.Hypot(p.X-x, p.Y-y) > radius {
+			continue
+		}
+		if addr, ok := clientAddrs[id]; ok {
+			writeUDP(addr, data)
+		}
+	}
+}
+
+// handleEmote пересылает эмоцию {"action":"emote","emote":"gg"} игрокам поблизости.
+// Эмоции не влияют на состояние игры и ограничены по частоте
+func handleEmote(player *Player, msg map[string]interface{}) {
+	emote, _ := msg["emote"].(string)
+	if !slices.Contains(config.Emotes, emote) {
+		sendToPlayer(player.ID, map[string]interface{}{
+			"error": "invalid_emote",
+			"emote": emote,
+		})
+		return
+	}
+
+	now := clock()
+	if now.Sub(player.LastEmoteTime) < time.Duration(config.EmoteCooldown) {
+		logDebug("Игрок %d отправляет эмоции слишком часто", player.ID)
+		return
+	}
+	player.LastEmoteTime = now
+
+	broadcastNearby(player.X, player.Y, config.ViewRange, map[string]interface{}{
+		"type":  "emote",
+		"from":  player.ID,
+		"emote": emote,
+	})
+}
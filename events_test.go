@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidEmoteRejected(t *testing.T) {
+	resetGame(t)
+	sender, senderClient := addTestPlayer(t, 100, 100)
+	_, nearClient := addTestPlayer(t, 150, 100)
+
+	handleEmote(sender, map[string]interface{}{"action": "emote", "emote": "<script>"})
+	senderClient.recvError("invalid_emote")
+	nearClient.expectNone(100*time.Millisecond, hasType("emote"))
+}
+
+func TestEmoteRelayedToNearbyOnly(t *testing.T) {
+	resetGame(t)
+	sender, senderClient := addTestPlayer(t, 100, 100)
+	_, nearClient := addTestPlayer(t, 300, 100)
+	_, farClient := addTestPlayer(t, 900, 700)
+
+	handleEmote(sender, map[string]interface{}{"action": "emote", "emote": "gg"})
+	emote := nearClient.recv("emote")
+	if emote["emote"] != "gg" || msgFloat(t, emote, "from") != float64(sender.ID) {
+		t.Fatalf("эмоция %v", emote)
+	}
+	senderClient.recv("emote")
+	farClient.expectNone(100*time.Millisecond, hasType("emote"))
+}
+
+func TestFreezeImmobilizesNearbyPlayers(t *testing.T) {
+	resetGame(t)
+	actor, actorClient := addTestPlayer(t, 400, 400)
+	near1, near1Client := addTestPlayer(t, 450, 400)
+	near2, _ := addTestPlayer(t, 400, 470)
+	far, farClient := addTestPlayer(t, 700, 700)
+
+	handleUDPMessage(actorClient.addr(), map[string]interface{}{"id": float64(actor.ID), "action": "freeze"})
+	now := time.Now()
+	for _, player := range []*Player{near1, near2} {
+		if !isFrozen(player, now) {
+			t.Fatalf("игрок %d рядом не заморожен", player.ID)
+		}
+	}
+	if isFrozen(far, now) || isFrozen(actor, now) {
+		t.Fatal("заморожен дальний игрок или сам применивший")
+	}
+	if near1.X != 450 || near2.Y != 470 {
+		t.Fatal("заморозка сдвинула игроков")
+	}
+
+	// Замороженный не двигается и не действует, дальний — как обычно
+	handleUDPMessage(near1Client.addr(), map[string]interface{}{"id": float64(near1.ID), "x": 460.0, "y": 400.0})
+	handleUDPMessage(near1Client.addr(), map[string]interface{}{"id": float64(near1.ID), "action": "push"})
+	near1Client.recv("correction")
+	handleUDPMessage(farClient.addr(), map[string]interface{}{"id": float64(far.ID), "x": 710.0, "y": 700.0})
+	if near1.X != 450 || isKnockedBack(actor) || far.X != 710 {
+		t.Fatalf("замороженный в x=%v, отброшен ли применивший: %v, дальний в x=%v", near1.X, isKnockedBack(actor), far.X)
+	}
+
+	// Оставшаяся заморозка видна в состоянии
+	gameTick(time.Now(), 10*time.Millisecond, false)
+	list, _ := farClient.recvState()["players"].([]interface{})
+	for _, item := range list {
+		entry := item.(map[string]interface{})
+		frozen := msgFloat(t, entry, "frozen") > 0
+		if id := int(msgFloat(t, entry, "id")); frozen != (id == near1.ID || id == near2.ID) {
+			t.Fatalf("игрок %d в состоянии: frozen=%v", id, entry["frozen"])
+		}
+	}
+}
+
+func TestJoinEventSentToExistingPlayers(t *testing.T) {
+	resetGame(t)
+	first, firstClient := addTestPlayer(t, 100, 100)
+	client := newTestClient(t)
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "name": "second", "skin": "blue"})
+	second := players[nextPlayerID]
+
+	msg, ok := firstClient.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "join" })
+	if !ok {
+		t.Fatal("первый игрок не узнал о подключении второго")
+	}
+	who, _ := msg["player"].(map[string]interface{})
+	if msgFloat(t, who, "id") != float64(second.ID) || who["name"] != "second" || who["skin"] != "blue" {
+		t.Fatalf("событие подключения: %v", msg)
+	}
+	// Событие надёжное: оно ждёт подтверждения, а сам подключившийся его не получает
+	if _, ok := msg["rseq"]; !ok || len(reliableQueues[first.ID]) != 1 {
+		t.Fatalf("событие отправлено ненадёжно: %v", msg)
+	}
+	client.expectNone(100*time.Millisecond, func(m map[string]interface{}) bool { return m["event"] == "join" })
+}
+
+func TestResyncRateLimited(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	_, other := addTestPlayer(t, 700, 700)
+	resync := func() {
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "action": "resync"})
+	}
+
+	resync()
+	reply := client.recv("resync")
+	state, _ := reply["state"].(map[string]interface{})
+	if state["keyframe"] != true || len(state["players"].([]interface{})) != 2 {
+		t.Fatalf("ответ на resync: %v", reply)
+	}
+	other.expectNone(50*time.Millisecond, hasType("resync"))
+
+	// Повторный запрос сразу же игнорируется, после resyncCooldown — снова работает
+	resync()
+	client.expectNone(100*time.Millisecond, hasType("resync"))
+	player.LastResyncTime = time.Now().Add(-resyncCooldown)
+	resync()
+	client.recv("resync")
+}
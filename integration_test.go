@@ -0,0 +1,260 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestServer запускает сервер целиком на свободном порту и
+// останавливает его через shutdown по окончании теста. configure
+// вызывается до запуска, пока циклы сервера не трогают config. Возвращённый
+// канал закрывается, когда serve вернёт управление
+func startTestServer(t *testing.T, configure func(*Config)) <-chan struct{} {
+	t.Helper()
+	resetGame(t)
+	config.SpawnProtection = 0
+	config.Workers = 2
+	if configure != nil {
+		configure(&config)
+	}
+	if config.DTLSListenAddr != "" {
+		if err := listenDTLS(config.DTLSListenAddr, config.DTLSCertFile, config.DTLSKeyFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		serve()
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		shutdown()
+		select {
+		case <-stopped:
+		case <-time.After(testTimeout):
+			t.Error("сервер не остановился после shutdown")
+		}
+	})
+	return stopped
+}
+
+// join подключает клиента через рукопожатие и возвращает выданный ID
+func (c *testClient) join(name string) int {
+	c.t.Helper()
+	c.send(map[string]interface{}{"type": "join", "protocolVersion": protocolVersion, "name": name})
+	return int(msgFloat(c.t, c.recv("joined"), "id"))
+}
+
+// waitState ждёт снимок состояния, в котором игрок id удовлетворяет match.
+// Снимки рассылаются каждый тик, поэтому потерянный или запоздавший пакет
+// только откладывает проверку до следующего снимка
+func (c *testClient) waitState(id int, match func(player map[string]interface{}) bool) map[string]interface{} {
+	c.t.Helper()
+	var last map[string]interface{}
+	_, ok := c.next(testTimeout, func(m map[string]interface{}) bool {
+		list, _ := m["players"].([]interface{})
+		if _, isState := m["capturePoints"]; !isState {
+			return false
+		}
+		for _, item := range list {
+			p, _ := item.(map[string]interface{})
+			if p["id"] == float64(id) {
+				last = p
+				return match(p)
+			}
+		}
+		return false
+	})
+	if !ok {
+		c.t.Fatalf("не дождались состояния игрока %d, последнее: %v", id, last)
+	}
+	return last
+}
+
+func TestServerJoinMoveAndPush(t *testing.T) {
+	startTestServer(t, nil)
+
+	first := newTestClient(t)
+	firstID := first.join("first")
+	second := newTestClient(t)
+	secondID := second.join("second")
+
+	// Второй игрок встаёт рядом с первым
+	second.send(map[string]interface{}{"id": secondID, "x": 450.0, "y": 400.0, "seq": 1})
+	state := first.waitState(secondID, func(p map[string]interface{}) bool {
+		return p["x"] == 450.0 && p["lastProcessedInput"] == 1.0
+	})
+	if state["y"] != 400.0 {
+		t.Fatalf("позиция второго игрока: %v", state)
+	}
+
+	// Толчок первого отбрасывает второго дальше по X
+	first.send(map[string]interface{}{"id": firstID, "action": "push", "seq": 1})
+	first.waitState(secondID, func(p map[string]interface{}) bool {
+		x, _ := p["x"].(float64)
+		return x > 460
+	})
+}
+
+func TestServerRejectsForeignAddress(t *testing.T) {
+	startTestServer(t, nil)
+
+	owner := newTestClient(t)
+	id := owner.join("owner")
+	owner.send(map[string]interface{}{"id": id, "x": 420.0, "y": 410.0})
+	owner.waitState(id, func(p map[string]interface{}) bool { return p["x"] == 420.0 })
+
+	// Чужой адрес не может двигать игрока
+	intruder := newTestClient(t)
+	intruder.send(map[string]interface{}{"id": id, "x": 900.0, "y": 700.0})
+	time.Sleep(50 * time.Millisecond)
+	owner.waitState(id, func(p map[string]interface{}) bool { return true })
+
+	mutex.Lock()
+	x, y := players[id].X, players[id].Y
+	mutex.Unlock()
+	if x != 420 || y != 410 {
+		t.Fatalf("игрок сдвинут с чужого адреса: (%v, %v)", x, y)
+	}
+}
+
+func TestServerLeaveAndShutdown(t *testing.T) {
+	startTestServer(t, nil)
+
+	stay := newTestClient(t)
+	stayID := stay.join("stay")
+	leave := newTestClient(t)
+	leaveID := leave.join("leave")
+
+	leave.send(map[string]interface{}{"type": "leave", "id": leaveID})
+	event, ok := stay.next(testTimeout, func(m map[string]interface{}) bool {
+		return m["event"] == "leave" && m["id"] == float64(leaveID)
+	})
+	if !ok {
+		t.Fatal("оставшийся игрок не получил событие выхода")
+	}
+	if event["reason"] != "left" {
+		t.Fatalf("причина выхода: %v", event["reason"])
+	}
+	stay.waitState(stayID, func(map[string]interface{}) bool { return true })
+
+	shutdown()
+	stay.recv("shutdown")
+}
+
+func TestShutdownFlushesLeaderboardAndStopsMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leaderboard.json")
+	stopped := startTestServer(t, func(cfg *Config) { cfg.LeaderboardFile = path })
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveMetrics(listener)
+	url := "http://" + listener.Addr().String() + "/metrics"
+	if resp, err := http.Get(url); err != nil {
+		t.Fatal(err)
+	} else {
+		resp.Body.Close()
+	}
+
+	// Итоги матча попадают в очередь записи перед самой остановкой
+	mutex.Lock()
+	queueLeaderboardSave(leaderboardSave{path: path, data: []byte(`{"final": {"name": "last"}}`)})
+	mutex.Unlock()
+	shutdown()
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("сервер не остановился после shutdown")
+	}
+
+	// serve возвращается только после записи таблицы
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "last") {
+		t.Fatalf("таблица лидеров не записана до выхода: %q, %v", data, err)
+	}
+	if resp, err := http.Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatal("сервер метрик отвечает после shutdown")
+	}
+}
+
+func TestServerPongUpdatesPing(t *testing.T) {
+	startTestServer(t, func(cfg *Config) { cfg.PingInterval = Duration(20 * time.Millisecond) })
+
+	client := newTestClient(t)
+	id := client.join("ping")
+	ping := client.recv("ping")
+	client.send(map[string]interface{}{"type": "pong", "id": id, "t": ping["t"]})
+
+	state := client.waitState(id, func(p map[string]interface{}) bool { return p["ping"] != -1.0 })
+	if ms := state["ping"].(float64); ms < 0 || math.IsNaN(ms) || ms > float64(testTimeout.Milliseconds()) {
+		t.Fatalf("задержка %v мс", ms)
+	}
+}
+
+func TestServerSendsPeriodicKeyframes(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	startTestServer(t, func(cfg *Config) { cfg.KeyframeInterval = Duration(interval) })
+	client := newTestClient(t)
+	client.join("keyframes")
+
+	// Между ключевыми кадрами идут обычные снимки, а сами кадры приходят с
+	// заданным интервалом
+	var keyframes []time.Time
+	deltas := 0
+	for len(keyframes) < 4 {
+		state := client.recvState()
+		if state["keyframe"] == true {
+			keyframes = append(keyframes, time.Now())
+		} else if len(keyframes) > 0 {
+			deltas++
+		}
+	}
+	for i := 1; i < len(keyframes); i++ {
+		if gap := keyframes[i].Sub(keyframes[i-1]); gap < interval/2 || gap > 3*interval {
+			t.Fatalf("между ключевыми кадрами %v, ожидалось около %v", gap, interval)
+		}
+	}
+	if deltas < len(keyframes) {
+		t.Fatalf("между ключевыми кадрами всего %d обычных снимков", deltas)
+	}
+}
+
+func TestServerEpochPerInstance(t *testing.T) {
+	resetGame(t)
+	start := func() uint32 {
+		conn.Close()
+		if err := listen("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return serverEpoch
+	}
+
+	first := start()
+	// Внутри одного запуска эпоха не меняется: её видят все клиенты и снимки
+	for i := 0; i < 2; i++ {
+		client := newTestClient(t)
+		handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion)})
+		if epoch := msgFloat(t, client.recv("joined"), "serverEpoch"); uint32(epoch) != first {
+			t.Fatalf("клиент %d получил эпоху %v, ожидалась %d", i+1, epoch, first)
+		}
+	}
+	state := currentGameState(false)
+	releasePlayersState(state.Players)
+	if state.Epoch != first {
+		t.Fatalf("эпоха в снимке %d, ожидалась %d", state.Epoch, first)
+	}
+
+	if second := start(); second == first {
+		t.Fatalf("у двух запусков сервера одна эпоха %d", first)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	knockbackStep            = 16 * time.Millisecond // Шаг, к которому относится коэффициент затухания KnockbackDecay
+	defaultKnockbackDuration = 10 * knockbackStep    // Длительность отброса по умолчанию
+	knockbackMinSpeed        = 1.0                   // Скорость, ниже которой отброс считается завершённым
+)
+
+// knockbackRate возвращает скорость затухания отброса в 1/с по KnockbackDecay,
+// вызывается под mutex
+func knockbackRate() float64 {
+	decay := config.KnockbackDecay
+	if decay <= 0 || decay > 1 {
+		decay = 1
+	}
+	return -math.Log(decay) / knockbackStep.Seconds()
+}
+
+// knockbackDuration возвращает длительность отброса из конфигурации. Значения
+// отклоняются при загрузке, но нулевая длительность здесь всё равно не
+// допускается: на неё делится суммарное смещение. Вызывается под mutex
+func knockbackDuration() time.Duration {
+	if d := time.Duration(config.KnockbackDuration); d > 0 {
+		return d
+	}
+	return defaultKnockbackDuration
+}
+
+// applyKnockback придаёт target скорость в направлении единичного вектора
+// (dirX, dirY) так, чтобы за время отброса он сместился на total единиц,
+// делённых на массу его скина и ограниченных MaxKnockbackDistance.
+// Скорость затухает в игровом тике (трение), поэтому игрок плавно
+// останавливается. Возвращает false, если отброс отклонён из-за
+// MaxActiveKnockbacks. Вызывается под mutex
+func applyKnockback(target *Player, dirX, dirY, total float64) bool {
+	// Число одновременно отброшенных игроков ограничено, чтобы поток действий
+	// не раздувал работу тика. Повторный отброс того же игрока заменяет прежний
+	if limit := config.MaxActiveKnockbacks; limit > 0 && !isKnockedBack(target) && activeKnockbacks() >= limit {
+		logDebug("Отброс игрока %d отклонён: активных отбросов уже %d", target.ID, limit)
+		return false
+	}
+
+	rate := knockbackRate()
+	duration := knockbackDuration().Seconds()
+	total /= target.Attrs.Mass
+	// Сильный толчок не уносит игрока дальше MaxKnockbackDistance
+	if limit := config.MaxKnockbackDistance; limit > 0 {
+		total = min(total, limit)
+	}
+
+	// Начальная скорость подбирается так, чтобы интеграл затухающей скорости был равен total
+	speed := total / duration
+	if rate > 0 {
+		speed = total * rate / (1 - math.Exp(-rate*duration))
+	}
+
+	target.VX = dirX * speed
+	target.VY = dirY * speed
+	target.KnockbackUntil = clock().Add(knockbackDuration())
+	return true
+}
+
+// isKnockedBack проверяет, движется ли игрок по инерции отброса, вызывается под mutex
+func isKnockedBack(player *Player) bool {
+	return player.VX != 0 || player.VY != 0
+}
+
+// activeKnockbacks считает отброшенных игроков, вызывается под mutex
+func activeKnockbacks() int {
+	n := 0
+	for _, player := range players {
+		if isKnockedBack(player) {
+			n++
+		}
+	}
+	return n
+}
+
+// integrateKnockback перемещает отброшенных игроков на dt по их скорости и
+// гасит её, вызывается под mutex из игрового тика
+func integrateKnockback(now time.Time, dt time.Duration) {
+	friction := math.Exp(-knockbackRate() * dt.Seconds())
+
+	for _, player := range players {
+		if !isKnockedBack(player) {
+			continue
+		}
+
+		// Обновляем позицию
+		nextX := player.X + player.VX*dt.Seconds()
+		nextY := player.Y + player.VY*dt.Seconds()
+		var blocked bool
+		oldX, oldY := player.X, player.Y
+		player.X, player.Y, blocked = moveWithCollision(player.X, player.Y, nextX, nextY)
+		player.KnockbackShift += math.Hypot(player.X-oldX, player.Y-oldY)
+		if blocked {
+			// Удар о препятствие: погашенное смещение превращается в урон,
+			// а отброс прекращается
+			applyWallDamage(player, math.Hypot(nextX-player.X, nextY-player.Y))
+			stopKnockback(player)
+			continue
+		}
+
+		player.VX *= friction
+		player.VY *= friction
+		if !now.Before(player.KnockbackUntil) || math.Hypot(player.VX, player.VY) < knockbackMinSpeed {
+			stopKnockback(player)
+		}
+	}
+}
+
+// stopKnockback обнуляет скорость отброса, вызывается под mutex
+func stopKnockback(player *Player) {
+	player.VX, player.VY = 0, 0
+}
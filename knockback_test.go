@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPushRangeConfigurable(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 480, 400)
+
+	config.PushRange = 60
+	applyPush(actor)
+	if isKnockedBack(target) {
+		t.Fatal("цель за пределами уменьшенной дальности отброшена")
+	}
+
+	config.PushRange = defaultConfig().PushRange
+	applyPush(actor)
+	if !isKnockedBack(target) {
+		t.Fatal("цель в пределах дальности по умолчанию не отброшена")
+	}
+}
+
+func TestPullRangeConfigurable(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 480, 400)
+
+	config.PullRange = 60
+	applyPull(actor)
+	if isKnockedBack(target) {
+		t.Fatal("цель за пределами уменьшенной дальности притянута")
+	}
+	config.PullRange = 100
+	applyPull(actor)
+	if target.VX >= 0 {
+		t.Fatalf("цель не притянута к игроку: vx=%v", target.VX)
+	}
+}
+
+// stepKnockback продвигает отброс тиками по knockbackStep, пока он не закончится
+func stepKnockback(t *testing.T, player *Player) (ticks int) {
+	t.Helper()
+	now := time.Now()
+	for isKnockedBack(player) {
+		now = now.Add(knockbackStep)
+		integrateKnockback(now, knockbackStep)
+		ticks++
+		if ticks > 1000 {
+			t.Fatal("отброс не заканчивается")
+		}
+	}
+	return ticks
+}
+
+func TestWallDamage(t *testing.T) {
+	resetGame(t)
+	config.WallDamage = 1
+	actor, _ := addTestPlayer(t, 960, 400)
+	target, _ := addTestPlayer(t, 990, 400)
+
+	applyPush(actor)
+	stepKnockback(t, target)
+	if target.HP >= config.MaxHP || target.X != config.WorldWidth {
+		t.Fatalf("удар о стену: hp=%d, x=%v", target.HP, target.X)
+	}
+
+	// В открытом пространстве толчок урона не наносит
+	actor.X, actor.Y = 450, 400
+	target.X, target.Y, target.HP = 480, 400, config.MaxHP
+	applyPush(actor)
+	stepKnockback(t, target)
+	if target.HP != config.MaxHP || target.X <= 480 {
+		t.Fatalf("толчок в открытое пространство: hp=%d, x=%v", target.HP, target.X)
+	}
+}
+
+func TestKnockbackDecelerates(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+	applyPush(actor)
+
+	now := time.Now()
+	last := math.Inf(1)
+	for ticks := 0; isKnockedBack(target); ticks++ {
+		if ticks > 1000 {
+			t.Fatal("отброс не заканчивается")
+		}
+		x := target.X
+		now = now.Add(knockbackStep)
+		integrateKnockback(now, knockbackStep)
+		step := target.X - x
+		if step <= 0 || step >= last {
+			t.Fatalf("тик %d: смещение %v после %v, ожидалось убывающее положительное", ticks, step, last)
+		}
+		last = step
+	}
+}
+
+// TestPushDuringTicks толкает игрока из обработчика, пока игровой цикл
+// интегрирует отброс, а затем отключает цель посреди отброса. Запускать с -race
+func TestPushDuringTicks(t *testing.T) {
+	resetGame(t)
+	actor, actorClient := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+	config.PushCooldown = 0
+
+	stopTicks := make(chan struct{})
+	ticks := make(chan struct{})
+	go func() {
+		defer close(ticks)
+		now := time.Now()
+		for {
+			select {
+			case <-stopTicks:
+				return
+			default:
+			}
+			mutex.Lock()
+			now = now.Add(knockbackStep)
+			gameTick(now, knockbackStep, false)
+			mutex.Unlock()
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		handleUDPMessage(actorClient.addr(), map[string]interface{}{"id": float64(actor.ID), "action": "push"})
+	}
+	// Цель отключается, как только тик сдвинул её, не дожидаясь конца отброса
+	deadline := time.Now().Add(testTimeout)
+	for {
+		mutex.Lock()
+		moved := target.X > 450
+		if moved {
+			removePlayer(target.ID, "left")
+		}
+		mutex.Unlock()
+		if moved {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("цель не сдвинулась")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(stopTicks)
+	<-ticks
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := players[target.ID]; ok {
+		t.Fatal("отключённая цель осталась в игре")
+	}
+}
+
+func TestPushRecoil(t *testing.T) {
+	for _, recoil := range []float64{0, 0.5} {
+		resetGame(t)
+		config.PushRecoil = recoil
+		actor, _ := addTestPlayer(t, 400, 400)
+		target, _ := addTestPlayer(t, 450, 400)
+
+		applyPush(actor)
+		// Тики продвигают всех игроков сразу
+		stepKnockback(t, target)
+		stepKnockback(t, actor)
+		pushed := target.X - 450
+		back := 400 - actor.X
+		if actor.Y != 400 || back < 0 {
+			t.Fatalf("отдача %v: толкнувший сместился в (%v, %v)", recoil, actor.X, actor.Y)
+		}
+		if math.Abs(back-recoil*pushed) > 0.1*pushed {
+			t.Errorf("отдача %v: толкнувший отброшен на %v при толчке на %v", recoil, back, pushed)
+		}
+	}
+}
+
+func TestDuplicatePushFiresOnce(t *testing.T) {
+	resetGame(t)
+	config.PushCooldown = 0
+	actor, client := addTestPlayer(t, 400, 400)
+	addTestPlayer(t, 450, 400)
+
+	// UDP продублировал пакет: одинаковые байты с тем же seq
+	data := []byte(fmt.Sprintf(`{"id": %d, "action": "push", "seq": 5}`, actor.ID))
+	handlePacket(client.addr(), data, false)
+	handlePacket(client.addr(), data, false)
+	if actor.Stats.PushesLanded != 1 {
+		t.Fatalf("push сработал %d раз, ожидался один", actor.Stats.PushesLanded)
+	}
+
+	handlePacket(client.addr(), []byte(fmt.Sprintf(`{"id": %d, "action": "push", "seq": 6}`, actor.ID)), false)
+	if actor.Stats.PushesLanded != 2 {
+		t.Fatalf("следующее действие не сработало: толчков %d", actor.Stats.PushesLanded)
+	}
+}
+
+func TestGlobalCooldownBlocksChaining(t *testing.T) {
+	for _, global := range []time.Duration{0, time.Second} {
+		resetGame(t)
+		config.GlobalCooldown = Duration(global)
+		actor, client := addTestPlayer(t, 400, 400)
+		addTestPlayer(t, 450, 400)
+
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(actor.ID), "action": "push"})
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(actor.ID), "action": "pull"})
+		if actor.Stats.PushesLanded != 1 {
+			t.Fatalf("общая перезарядка %v: push не сработал", global)
+		}
+		// Своя перезарядка pull не тронута, блокирует только общая
+		if pulled := actor.Stats.PullsLanded == 1; pulled != (global == 0) {
+			t.Fatalf("общая перезарядка %v: pull сразу после push сработал: %v", global, pulled)
+		}
+	}
+}
+
+func TestKnockbackDurationSetsDisplacementAndTicks(t *testing.T) {
+	for _, tt := range []struct {
+		duration time.Duration
+		ticks    int
+	}{
+		{10 * knockbackStep, 10},
+		{20 * knockbackStep, 20},
+	} {
+		resetGame(t)
+		// Без трения скорость постоянна, и смещение за тики считается точно
+		config.KnockbackDecay = 1
+		config.KnockbackDuration = Duration(tt.duration)
+		actor, _ := addTestPlayer(t, 400, 400)
+		target, _ := addTestPlayer(t, 450, 400)
+
+		applyPush(actor)
+		ticks := stepKnockback(t, target)
+		want := config.PushStrength / 50
+		if ticks != tt.ticks || math.Abs(target.X-450-want) > 1e-6 {
+			t.Errorf("длительность %v: смещение %v за %d тиков, ожидалось %v за %d",
+				tt.duration, target.X-450, ticks, want, tt.ticks)
+		}
+	}
+
+	if _, err := loadConfig(writeConfig(t, `{"knockbackDuration": "0s"}`)); err == nil {
+		t.Fatal("нулевая длительность отброса принята")
+	}
+}
+
+func TestEquidistantPushPicksLowerID(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	lower, _ := addTestPlayer(t, 450, 400)
+	higher, _ := addTestPlayer(t, 350, 400)
+
+	// Порядок обхода карты игроков случаен, поэтому проверка повторяется
+	for i := 0; i < 50; i++ {
+		applyPush(actor)
+		if !isKnockedBack(lower) || isKnockedBack(higher) {
+			t.Fatalf("попытка %d: отброшен игрок с большим ID", i+1)
+		}
+		stopKnockback(lower)
+		stopKnockback(actor)
+	}
+}
+
+func TestActiveKnockbacksCapped(t *testing.T) {
+	resetGame(t)
+	config.MaxActiveKnockbacks = 2
+	var actors, targets []*Player
+	for i := 0; i < 4; i++ {
+		y := 100 + 150*float64(i)
+		actor, _ := addTestPlayer(t, 100, y)
+		target, _ := addTestPlayer(t, 150, y)
+		actors, targets = append(actors, actor), append(targets, target)
+	}
+
+	for _, actor := range actors {
+		applyPush(actor)
+	}
+	if n := activeKnockbacks(); n != 2 {
+		t.Fatalf("активных отбросов %d, ожидалось 2", n)
+	}
+	if !isKnockedBack(targets[0]) || !isKnockedBack(targets[1]) || isKnockedBack(targets[2]) || isKnockedBack(targets[3]) {
+		t.Fatal("отброшены не первые две цели")
+	}
+	if actors[2].Stats.PushesLanded != 0 {
+		t.Fatal("отклонённый толчок засчитан")
+	}
+
+	// Повторный толчок уже отброшенной цели заменяет прежний отброс
+	applyPush(actors[0])
+	if actors[0].Stats.PushesLanded != 2 {
+		t.Fatal("повторный толчок отброшенной цели отклонён")
+	}
+
+	// Когда отбросы закончились, место освобождается
+	stepKnockback(t, targets[0])
+	stepKnockback(t, targets[1])
+	applyPush(actors[2])
+	if !isKnockedBack(targets[2]) {
+		t.Fatal("толчок после окончания отбросов отклонён")
+	}
+}
+
+func TestKnockbackDisplacementClamped(t *testing.T) {
+	resetGame(t)
+	config.KnockbackDecay = 1
+	config.PushStrength = 100000
+	config.MaxKnockbackDistance = 120
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+
+	applyPush(actor)
+	stepKnockback(t, target)
+	if math.Abs(target.X-450-config.MaxKnockbackDistance) > 1e-6 || target.Y != 400 {
+		t.Fatalf("смещение (%v, %v), ожидалось %v вдоль толчка", target.X-450, target.Y-400, config.MaxKnockbackDistance)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LeaderboardEntry — суммарная статистика игрока за все матчи
+type LeaderboardEntry struct {
+	Name     string `json:"name"`
+	Matches  int    `json:"matches"`
+	Wins     int    `json:"wins"`
+	Points   int    `json:"points"`
+	Captures int    `json:"captures"`
+}
+
+// leaderboardSave — снимок таблицы для записи на диск
+type leaderboardSave struct {
+	path string
+	data []byte
+}
+
+var (
+	// Таблица по ключу игрока (хеш имени и токена), защищена mutex
+	leaderboard = make(map[string]*LeaderboardEntry)
+
+	// Очередь записи на диск. Хранится только последний снимок, чтобы запись
+	// не блокировала игровой цикл
+	leaderboardSaves = make(chan leaderboardSave, 1)
+)
+
+// playerKey возвращает стабильный идентификатор игрока для таблицы лидеров.
+// Хранится хеш, чтобы токены не попадали в файл
+func playerKey(player *Player) string {
+	sum := sha256.Sum256([]byte(player.Name + "\n" + player.Token))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadLeaderboard читает таблицу лидеров из файла, отсутствие файла не ошибка
+func loadLeaderboard(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &leaderboard)
+}
+
+// recordMatch добавляет результаты завершённого матча в таблицу лидеров,
+// вызывается под mutex
+func recordMatch(winner *Player) {
+	for _, player := range players {
+		key := playerKey(player)
+		entry, ok := leaderboard[key]
+		if !ok {
+			entry = &LeaderboardEntry{}
+			leaderboard[key] = entry
+		}
+		entry.Name = player.Name
+		entry.Matches++
+		entry.Points += player.Points
+		entry.Captures += player.Stats.Captures
+		if player == winner {
+			entry.Wins++
+		}
+	}
+
+	if config.LeaderboardFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(leaderboard, "", "  ")
+	if err != nil {
+		log.Println("Ошибка сериализации таблицы лидеров:", err)
+		return
+	}
+	queueLeaderboardSave(leaderboardSave{path: config.LeaderboardFile, data: data})
+}
+
+// queueLeaderboardSave ставит снимок в очередь записи, вытесняя ещё не записанный
+func queueLeaderboardSave(save leaderboardSave) {
+	for {
+		select {
+		case leaderboardSaves <- save:
+			return
+		default:
+			select {
+			case <-leaderboardSaves:
+			default:
+			}
+		}
+	}
+}
+
+// leaderboardWriter записывает снимки таблицы лидеров на диск. При остановке
+// сервера дописывает ожидающий снимок, чтобы итоги последнего матча не
+// потерялись
+func leaderboardWriter() {
+	for {
+		select {
+		case save := <-leaderboardSaves:
+			writeLeaderboard(save)
+		case <-serverDone:
+			select {
+			case save := <-leaderboardSaves:
+				writeLeaderboard(save)
+			default:
+			}
+			return
+		}
+	}
+}
+
+// writeLeaderboard записывает снимок таблицы лидеров
+func writeLeaderboard(save leaderboardSave) {
+	if err := writeFileAtomic(save.path, save.data); err != nil {
+		log.Println("Ошибка записи таблицы лидеров:", err)
+	}
+}
+
+// writeFileAtomic пишет файл через временный файл и переименование, чтобы
+// при сбое на диске не осталось наполовину записанной таблицы
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// topLeaderboard возвращает n лучших записей по очкам, вызывается под mutex
+func topLeaderboard(n int) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(leaderboard))
+	for _, entry := range leaderboard {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
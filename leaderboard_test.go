@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLeaderboardAccumulatesAcrossMatches(t *testing.T) {
+	resetGame(t)
+	config.LeaderboardFile = filepath.Join(t.TempDir(), "leaderboard.json")
+	winner, _ := addTestPlayer(t, 100, 100)
+	loser, _ := addTestPlayer(t, 900, 900)
+	winner.Name, loser.Name = "winner", "loser"
+
+	winner.Points, loser.Points = 5, 3
+	winner.Stats.Captures = 1
+	endMatch(winner)
+	restartMatch()
+	winner.Points, loser.Points = 4, 2
+	winner.Stats.Captures = 2
+	endMatch(winner)
+
+	// Последний снимок таблицы записывается на диск и читается заново
+	save := <-leaderboardSaves
+	if err := writeFileAtomic(save.path, save.data); err != nil {
+		t.Fatal(err)
+	}
+	leaderboard = make(map[string]*LeaderboardEntry)
+	if err := loadLeaderboard(config.LeaderboardFile); err != nil {
+		t.Fatal(err)
+	}
+
+	want := LeaderboardEntry{Name: winner.Name, Matches: 2, Wins: 2, Points: 9, Captures: 3}
+	if got := *leaderboard[playerKey(winner)]; got != want {
+		t.Fatalf("победитель: %+v, ожидалось %+v", got, want)
+	}
+	if got := leaderboard[playerKey(loser)]; got.Matches != 2 || got.Wins != 0 || got.Points != 5 {
+		t.Fatalf("проигравший: %+v", got)
+	}
+}
@@ -1,402 +1,1367 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"flag"
 	"log"
 	"math"
 	"net"
+	"slices"
 	"sync"
 	"time"
 )
 
 type Player struct {
-	ID           int       `json:"id"`
-	X            float64   `json:"x"`
-	Y            float64   `json:"y"`
-	FlipX        bool      `json:"flipX"`
-	LastPushTime time.Time // Время последнего действия "push"
-	LastPullTime time.Time // Время последнего действия "pull"
-	Name         string    `json:"name"`   // Добавляем JSON-тег для имени
-	Skin         string    `json:"skin"`   // Добавляем JSON-тег для скина
-	Points       int       `json:"points"` // Добавляем поле для очков
+	ID           int              `json:"id"`
+	X            float64          `json:"x"`
+	Y            float64          `json:"y"`
+	FlipX        bool             `json:"flipX"`
+	LastPushTime time.Time        `json:"-"`                   // Время последнего действия "push"
+	LastPullTime time.Time        `json:"-"`                   // Время последнего действия "pull"
+	PushCooldown float64          `json:"pushCooldown"`        // Сколько секунд ещё до готовности "push", 0 — готов
+	PullCooldown float64          `json:"pullCooldown"`        // Сколько секунд ещё до готовности "pull", 0 — готов
+	Name         string           `json:"name"`                // Добавляем JSON-тег для имени
+	Skin         string           `json:"skin"`                // Добавляем JSON-тег для скина
+	Color        string           `json:"color"`               // Цвет игрока для отрисовки, назначается сервером
+	Points       int              `json:"points"`              // Добавляем поле для очков
+	Ping         int              `json:"ping"`                // Сглаженная задержка в мс, -1 до первого замера
+	Facing       float64          `json:"facing"`              // Направление взгляда в радианах, [-π, π]
+	HP           int              `json:"hp"`                  // Здоровье
+	Protected    bool             `json:"protected"`           // Действует ли защита после появления
+	Buffs        []Buff           `json:"buffs,omitempty"`     // Действующие усиления
+	LastInputSeq int              `json:"lastProcessedInput"`  // Номер последнего обработанного ввода, по нему клиент отбрасывает подтверждённые вводы
+	Frozen       float64          `json:"frozen"`              // Сколько секунд ещё действует заморозка, 0 — не заморожен
+	Team         int              `json:"team"`                // Номер команды, 0 — игра без команд
+	LastStand    int              `json:"lastStand"`           // Множитель очков "последнего рубежа", 1 — не действует
+	Cooldowns    map[string]int64 `json:"cooldowns,omitempty"` // Оставшиеся перезарядки действий в мс, если CooldownsInState = all
+
+	ProtectedUntil time.Time `json:"-"` // Окончание защиты после появления
+	FrozenUntil    time.Time `json:"-"` // Окончание заморозки
+
+	Token  string         `json:"-"` // Токен игрока для таблицы лидеров
+	Stats  PlayerStats    `json:"-"` // Статистика за матч
+	Attrs  SkinAttributes `json:"-"` // Игровые параметры скина
+	Binary bool           `json:"-"` // Клиент получает позиции в двоичном формате
+	DTLS   bool           `json:"-"` // Клиент подключился по DTLS, открытые пакеты от его имени отклоняются
+
+	LastSeen         time.Time `json:"-"` // Время последнего сообщения от клиента, включая pong
+	MissedHeartbeats int       `json:"-"` // Сколько интервалов ping подряд от игрока не было сообщений
+	CooldownsPending bool      `json:"-"` // Клиенту отправлены ещё не истёкшие перезарядки
+	LastMoveTime     time.Time `json:"-"` // Время последнего принятого перемещения
+	LastActivity     time.Time `json:"-"` // Время последнего перемещения или действия
+	LastSwapTime     time.Time `json:"-"` // Время последнего действия "swap"
+	LastFreezeTime   time.Time `json:"-"` // Время последнего действия "freeze"
+	LastDashTime     time.Time `json:"-"` // Время последнего действия "dash"
+	LastActionTime   time.Time `json:"-"` // Время последнего действия любого вида, для общей перезарядки
+	LastResyncTime   time.Time `json:"-"` // Время последнего запроса полного состояния
+	LastEmoteTime    time.Time `json:"-"` // Время последней эмоции
+	InputQueue       []Point   `json:"-"` // Принятые, но ещё не применённые позиции (буфер ввода)
+	RecentActionSeqs []int     `json:"-"` // Номера последних действий для отсева дубликатов
+
+	MoveBucket   tokenBucket `json:"-"` // Ограничение частоты перемещений
+	ActionBucket tokenBucket `json:"-"` // Ограничение частоты действий
+	ChatBucket   tokenBucket `json:"-"` // Ограничение частоты эмоций
+
+	VX             float64   `json:"-"` // Скорость отброса по X, ед./с
+	VY             float64   `json:"-"` // Скорость отброса по Y, ед./с
+	KnockbackUntil time.Time `json:"-"` // Окончание отброса
+	KnockbackShift float64   `json:"-"` // Смещение отбросом с последнего принятого перемещения
 }
 
 type CapturePoint struct {
-	X                      float64   `json:"x"`
-	Y                      float64   `json:"y"`
-	Radius                 float64   `json:"radius"`
-	IsCaptured             bool      `json:"isCaptured"`
-	CapturingPlayer        int       `json:"capturingPlayer"`
-	CurrentCapturingPlayer int       `json:"currentCapturingPlayer"` // Добавлен JSON-тег
-	CaptureStart           time.Time `json:"captureStart"`
-	EnterTime              time.Time `json:"enterTime"`
+	ID                     int           `json:"id"`
+	X                      float64       `json:"x"`
+	Y                      float64       `json:"y"`
+	Radius                 float64       `json:"radius"`
+	IsCaptured             bool          `json:"isCaptured"`
+	CapturingPlayer        int           `json:"capturingPlayer"`
+	CurrentCapturingPlayer int           `json:"currentCapturingPlayer"` // Добавлен JSON-тег
+	CaptureStart           time.Time     `json:"-"`                      // Последнее начисление очков владельцу
+	EnterTime              time.Time     `json:"-"`                      // Начало захвата текущим захватчиком
+	HoldStart              time.Time     `json:"-"`                      // Начало непрерывного удержания текущим владельцем
+	StreakMultiplier       int           `json:"streakMultiplier"`       // Текущий множитель очков за удержание
+	ScoreWeight            int           `json:"scoreWeight"`            // Во сколько раз больше очков приносит точка
+	OwnerColor             string        `json:"ownerColor"`             // Цвет владельца или нейтральный, заполняется в снимке
+	OwnerTeam              int           `json:"ownerTeam"`              // Команда, для которой захвачена точка
+	Active                 bool          `json:"active"`                 // Можно ли сейчас захватить точку (при смене активных точек)
+	Progress               float64       `json:"progress"`               // Доля пройденного захвата текущим захватчиком, от 0 до 1
+	HeldFor                float64       `json:"heldFor"`                // Сколько секунд владелец непрерывно удерживает точку
+	NextScoreIn            float64       `json:"nextScoreIn"`            // Через сколько секунд владелец получит очки
+	Drain                  float64       `json:"drain"`                  // Насколько ослаб захват точки без владельца, от 0 до 1
+	MaxHold                time.Duration `json:"-"`                      // Наибольшее время удержания одним владельцем (0 — без ограничения)
+	RequiredPlayers        int           `json:"requiredPlayers"`        // Сколько игроков одной команды нужно в зоне для захвата
+	PausedAt               time.Time     `json:"-"`                      // Когда захват замер из-за нехватки игроков
+	CaptureTeam            int           `json:"-"`                      // Команда, чей отряд захватывает точку с RequiredPlayers > 1
+	DrainStart             time.Time     `json:"-"`                      // Когда владелец покинул точку, для ослабевания захвата
+
+	Occupants    map[int]time.Time `json:"-"` // Игроки в зоне и время их входа
+	Contributors map[int]time.Time `json:"-"` // Игроки, участвовавшие в захвате, и когда они последний раз были в зоне
 }
 
 type GameState struct {
 	Players       []Player       `json:"players"`
 	CapturePoints []CapturePoint `json:"capturePoints"`
+	Obstacles     []Obstacle     `json:"obstacles,omitempty"` // Только в ключевых кадрах
+
+	// Ключевой кадр содержит полное состояние, включая статичную геометрию.
+	// Клиент, пропустивший пакеты, восстанавливается по нему
+	Keyframe bool `json:"keyframe"`
+
+	// Эпоха сервера, по её смене клиент узнаёт о перезапуске
+	Epoch uint32 `json:"serverEpoch"`
 }
 
 var (
 	conn          *net.UDPConn // Глобальная переменная для UDP соединения
 	players       = make(map[int]*Player)
 	clientAddrs   = make(map[int]*net.UDPAddr) // Хранение адресов клиентов
-	capturePoints = []CapturePoint{
-		{X: 300, Y: 200, Radius: 50},
-		{X: 800, Y: 600, Radius: 50},
-		{X: 550, Y: 400, Radius: 50},
-	}
+	addrPlayers   = make(map[string][]int)     // Игроки, зарегистрированные с адреса
+	writeFailures = make(map[int]int)          // Подряд неудачные отправки состояния игроку
+	nextPlayerID  = 0                          // Последний выданный ID игрока
+	capturePoints []CapturePoint               // Точки захвата текущего матча, создаются из config.CapturePoints
 
-	mutex   = &sync.Mutex{}
-	udpAddr = net.UDPAddr{
-		Port: 8080,
-		IP:   net.ParseIP("0.0.0.0"),
-	}
+	mutex = &sync.Mutex{}
+
+	debugLogging bool // Выводить ли отладочные сообщения
+
+	binaryData []byte // Буфер двоичного снимка позиций, переиспользуется между тиками
+
+	// Случайное число, выбираемое в listen при каждом запуске сервера. После
+	// перезапуска сервер снова выдаёт маленькие ID, и клиент, сохранивший ID,
+	// отличает новый сервер от прежнего только по смене эпохи
+	serverEpoch uint32
 )
 
 func main() {
-	var err error
-	conn, err = net.ListenUDP("udp", &udpAddr)
+	flag.StringVar(&configPath, "config", "", "путь к JSON-файлу конфигурации")
+	flag.BoolVar(&debugLogging, "debug", false, "включить отладочные сообщения в логе")
+	flag.StringVar(&mapFlag, "map", "", "встроенная карта: "+mapPresetNames())
+	flag.Parse()
+
+	cfg, err := loadConfig(configPath)
 	if err != nil {
+		log.Fatal("Ошибка при загрузке конфигурации:", err)
+	}
+	config = cfg
+	seedRNG(config.Seed)
+	if config.LeaderboardFile != "" {
+		if err := loadLeaderboard(config.LeaderboardFile); err != nil {
+			log.Fatal("Ошибка при загрузке таблицы лидеров:", err)
+		}
+	}
+	startMatch()
+
+	if err := listen(config.ListenAddr); err != nil {
 		log.Fatal("Ошибка при прослушивании UDP:", err)
 	}
 	defer conn.Close()
+	if config.DTLSListenAddr != "" {
+		if err := listenDTLS(config.DTLSListenAddr, config.DTLSCertFile, config.DTLSKeyFile); err != nil {
+			log.Fatal("Ошибка при запуске DTLS:", err)
+		}
+	}
 
-	go gameLoop()
-	go checkCapturePoints()
+	go watchReloadSignal()
+	go watchShutdownSignal()
+	if config.AuditFile != "" {
+		if err := startAudit(config.AuditFile); err != nil {
+			log.Fatal("Ошибка при открытии журнала аудита:", err)
+		}
+	}
+	if config.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", config.MetricsAddr)
+		if err != nil {
+			log.Fatal("Ошибка при запуске сервера метрик:", err)
+		}
+		serveMetrics(listener)
+	}
+
+	serve()
+	log.Println("Сервер остановлен")
+}
+
+// listen открывает игровой сокет на addr и готовит сервер к запуску serve
+func listen(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	serverEpoch = newServerEpoch()
+	serverDone = make(chan struct{})
+	shutdownOnce = sync.Once{}
+	// При порте 0 система выбирает свободный порт, поэтому адрес выводится в лог
+	log.Printf("Сервер слушает %s", conn.LocalAddr())
+	return nil
+}
+
+// serve запускает игровые циклы и обработку пакетов открытого listen сокета.
+// Возвращает управление после shutdown, когда все циклы и обработчики завершатся
+func serve() {
+	for _, loop := range []func(){gameLoop, checkCapturePoints, pingLoop, reapPlayers, leaderboardWriter} {
+		serverLoops.Add(1)
+		go func() {
+			defer serverLoops.Done()
+			loop()
+		}()
+	}
 
-	buffer := make([]byte, 2048)
+	// Чтение сокета и разбор пакетов разнесены: один читатель складывает
+	// пакеты в очередь, а пул обработчиков разбирает их параллельно.
+	// Изменения состояния игры по-прежнему сериализуются через mutex
+	packets := make(chan packet, packetQueueSize)
+	var workers sync.WaitGroup
+	for i := 0; i < max(config.Workers, 1); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			packetWorker(packets)
+		}()
+	}
+	if dtlsListener != nil {
+		go acceptDTLS(packets)
+	}
+	readPackets(packets)
+	stopDTLS()
+	close(packets)
+	workers.Wait()
+	serverLoops.Wait()
+}
+
+// Размер очереди входящих пакетов между читателем и обработчиками
+const packetQueueSize = 1024
+
+// packet — входящий UDP-пакет, ожидающий разбора
+type packet struct {
+	addr   *net.UDPAddr
+	buf    *[]byte // Буфер из packetPool, возвращается обработчиком
+	n      int     // Длина пакета в буфере
+	secure bool    // Пакет пришёл по DTLS-сессии, а не открытым текстом
+}
+
+// readPackets читает сокет и передаёт пакеты обработчикам. Каждый пакет
+// читается в собственный буфер из пула, поэтому обработчики, работающие
+// параллельно с читателем, не видят чужих байтов
+func readPackets(packets chan<- packet) {
 	for {
-		n, addr, err := conn.ReadFromUDP(buffer)
+		buf := packetPool.Get().(*[]byte)
+		n, addr, err := conn.ReadFromUDP(*buf)
+		if errors.Is(err, net.ErrClosed) {
+			// Сокет закрыт при остановке сервера
+			packetPool.Put(buf)
+			return
+		}
 		if err != nil {
+			packetPool.Put(buf)
 			log.Println("Ошибка при чтении UDP:", err)
 			continue
 		}
+		enqueuePacket(packets, addr, buf, n, false)
+	}
+}
+
+// enqueuePacket учитывает принятый пакет и передаёт его обработчикам.
+// Если пакет отброшен, буфер возвращается в пул
+func enqueuePacket(packets chan<- packet, addr *net.UDPAddr, buf *[]byte, n int, secure bool) {
+	countReceived(addr, n)
+	metrics.packetsReceived.Add(1)
 
-		var msg map[string]interface{}
-		if err := json.Unmarshal(buffer[:n], &msg); err != nil {
+	// Буфер на байт больше допустимого: если он заполнен, датаграмма
+	// была обрезана и разбирать её бессмысленно
+	if n > maxPacketSize {
+		packetPool.Put(buf)
+		metrics.packetsDropped.Add(1)
+		logDebug("Слишком большой пакет от %s отброшен", addr)
+		return
+	}
+
+	select {
+	case packets <- packet{addr: addr, buf: buf, n: n, secure: secure}:
+	default:
+		packetPool.Put(buf)
+		metrics.packetsDropped.Add(1)
+		log.Printf("Очередь пакетов переполнена, пакет от %s отброшен", addr)
+	}
+}
+
+// packetWorker разбирает пакеты из очереди и возвращает их буферы в пул
+func packetWorker(packets <-chan packet) {
+	for p := range packets {
+		handlePacket(p.addr, (*p.buf)[:p.n], p.secure)
+		packetPool.Put(p.buf)
+	}
+}
+
+// handlePacket — единая точка входа для сырых байтов от клиента. Любой
+// входной пакет должен обрабатываться здесь без паники. secure — пакет
+// расшифрован из DTLS-сессии
+func handlePacket(addr *net.UDPAddr, data []byte, secure bool) {
+	var msg map[string]interface{}
+	// Двоичные перемещения начинаются с байта типа, JSON — с "{"
+	if len(data) > 0 && data[0] == binaryMove {
+		var err error
+		if msg, err = decodeMove(data); err != nil {
+			logDebug("Пакет от %s отброшен: %v", addr, err)
+			return
+		}
+	} else {
+		if err := json.Unmarshal(data, &msg); err != nil {
 			log.Println("Ошибка при разборе JSON:", err)
-			continue
+			return
+		}
+		if msg == nil {
+			// Например, пакет "null"
+			return
 		}
+	}
 
-		handleUDPMessage(addr, msg)
+	if !secure && !plaintextAllowed(addr, msg) {
+		logDebug("Открытый пакет от %s для DTLS-клиента отброшен", addr)
+		return
 	}
+	handleUDPMessage(addr, msg)
 }
 
+// Версия протокола, которую клиент должен указать при подключении
+const protocolVersion = 1
+
 func handleUDPMessage(addr *net.UDPAddr, msg map[string]interface{}) {
-	playerID := 0
-	if id, ok := msg["id"].(float64); ok {
-		playerID = int(id)
-	} else {
-		// Если id не указан, присваиваем новый ID
-		mutex.Lock()
-		playerID = len(players) + 1
-		players[playerID] = &Player{
-			ID:   playerID,
-			X:    400,
-			Y:    400,
-			Name: msg["name"].(string),
-			Skin: msg["skin"].(string),
-		}
-		clientAddrs[playerID] = addr // Сохраняем адрес клиента
-		log.Printf("Игрок %d подключился", playerID)
+	switch msgType, _ := msg["type"].(string); msgType {
+	case "join":
+		handleJoin(addr, msg)
+		return
+	case "admin":
+		handleAdmin(addr, msg)
+		return
+	}
+
+	// Зрители не управляют игроками: их сообщения только продлевают подключение
+	mutex.Lock()
+	isSpectator := touchSpectator(addr)
+	mutex.Unlock()
+	if isSpectator {
+		return
+	}
+
+	id, ok := msg["id"].(float64)
+	if !ok {
+		log.Printf("Сообщение без id от %s проигнорировано", addr)
+		return
+	}
+	playerID := int(id)
+
+	mutex.Lock()
+	player, ok := players[playerID]
+	if !ok {
+		mutex.Unlock()
+		log.Printf("Сообщение от неизвестного игрока %d", playerID)
+		return
+	}
+	// Управлять игроком можно только с адреса, с которого он подключился
+	if !ownsPlayer(addr, playerID) {
+		mutex.Unlock()
+		log.Printf("Сообщение от имени игрока %d с чужого адреса %s отклонено", playerID, addr)
+		return
+	}
+
+	player.LastSeen = clock()
+
+	switch msgType, _ := msg["type"].(string); msgType {
+	case "pong":
+		handlePong(player, msg)
+		mutex.Unlock()
+		return
+	case "ack":
+		handleAck(player, msg)
+		mutex.Unlock()
+		return
+	case "leave":
+		// Клиент вышел сам: не ждём PlayerTimeout. Адрес уже проверен выше,
+		// поэтому выгнать чужого игрока так нельзя
+		removePlayer(playerID, "left")
 		mutex.Unlock()
+		return
+	}
 
-		// Отправляем присвоенный playerID обратно клиенту
-		response := map[string]interface{}{
-			"id": playerID,
+	// Замороженный игрок не двигается и не действует, клиент возвращается на
+	// место коррекцией
+	if isFrozen(player, player.LastSeen) {
+		if _, ok := msg["action"]; ok {
+			logDebug("Игрок %d заморожен, действие отклонено", playerID)
 		}
-		sendUDPMessage(addr, response)
+		if hasPosition(msg) {
+			sendCorrection(player)
+		}
+		mutex.Unlock()
 		return
 	}
 
-	player := players[playerID]
+	// На паузе движение и атаки игнорируются, а чат и запросы работают
+	paused := matchPhase == phasePaused
+	if paused && hasPosition(msg) {
+		sendCorrection(player)
+	}
 
-	// Обработка сообщений, связанных с действиями игрока
-	if x, ok := msg["x"].(float64); ok {
-		player.X = x
+	// Обработка сообщений, связанных с действиями игрока. Лишние
+	// промежуточные позиции от слишком частого клиента отбрасываются
+	if !paused && (!hasPosition(msg) || allowMessage(player, &player.MoveBucket, config.MoveRateLimit, "move", player.LastSeen)) {
+		handleMovement(player, msg)
 	}
-	if y, ok := msg["y"].(float64); ok {
-		player.Y = y
+
+	if facing, ok := msg["facing"].(float64); ok {
+		if !isFinite(facing) {
+			log.Printf("Игрок %d прислал некорректное направление %v", playerID, facing)
+		} else {
+			player.Facing = normalizeAngle(facing)
+			// FlipX выводится из направления, если клиент не прислал его явно
+			player.FlipX = math.Cos(player.Facing) < 0
+		}
 	}
 	if flipX, ok := msg["flipX"].(bool); ok {
 		player.FlipX = flipX
 	}
-	if action, ok := msg["action"].(string); ok {
-		handleAction(player, action)
+	if action, ok := msg["action"].(string); ok && !(paused && isGameAction(action)) && !isDuplicateAction(player, msg) && allowAction(player, action) {
+		// Запросы состояния и эмоции не считаются игрой: иначе бездействующий
+		// клиент избегал бы IdleTimeout, периодически запрашивая статус
+		if isGameAction(action) {
+			player.LastActivity = player.LastSeen
+		}
+		handleAction(player, action, msg)
+	}
+	mutex.Unlock()
+
+	// Состояние игры клиент получает из рассылки gameLoop, отдельный ответ
+	// на каждое сообщение только удваивал бы трафик
+}
+
+// handleJoin обрабатывает рукопожатие
+// {"type":"join","protocolVersion":N,"name":...,"skin":...}
+func handleJoin(addr *net.UDPAddr, msg map[string]interface{}) {
+	version, _ := msg["protocolVersion"].(float64)
+	if int(version) != protocolVersion {
+		log.Printf("Клиент %s отклонён: версия протокола %v, ожидается %d", addr, version, protocolVersion)
+		sendUDPMessage(addr, map[string]interface{}{
+			"error":         "version_mismatch",
+			"serverVersion": protocolVersion,
+		})
+		return
+	}
+
+	req := joinRequest{encoding: encodingJSON}
+	req.name, _ = msg["name"].(string)
+	req.skin, _ = msg["skin"].(string)
+	req.token, _ = msg["token"].(string)
+	if encoding, _ := msg["encoding"].(string); encoding == encodingBinary {
+		req.encoding = encodingBinary
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if spectator, _ := msg["spectator"].(bool); spectator {
+		joinSpectator(addr)
+		return
+	}
+	// Один адрес регистрирует только одного игрока, если не разрешено иное
+	if ids := addrPlayers[addr.String()]; len(ids) > 0 && !config.AllowMultiplePerAddress {
+		sendUDPMessage(addr, map[string]interface{}{
+			"error": "already_joined",
+			"id":    ids[0],
+		})
+		return
+	}
+	if len(players) >= playerLimit() {
+		if config.WaitingRoom {
+			enqueueWaiting(addr, req)
+			return
+		}
+		log.Printf("Клиент %s отклонён: сервер заполнен", addr)
+		sendUDPMessage(addr, map[string]interface{}{"error": "server_full"})
+		return
 	}
 
-	// Отправка состояния игры обратно игроку
-	sendGameState(addr)
+	addPlayer(addr, req)
+}
+
+// joinRequest — параметры игрока из сообщения "join"
+type joinRequest struct {
+	name     string
+	skin     string
+	token    string
+	encoding string
+}
+
+// addPlayer создаёт игрока на точке появления и отправляет клиенту ответ на
+// рукопожатие, вызывается под mutex
+func addPlayer(addr *net.UDPAddr, req joinRequest) {
+	name, skin, token, encoding := req.name, req.skin, req.token, req.encoding
+
+	nextPlayerID++
+	playerID := nextPlayerID
+	now := clock()
+	team := assignTeam()
+	spawnX, spawnY := spawnPosition(team)
+	player := &Player{
+		ID:             playerID,
+		X:              spawnX,
+		Y:              spawnY,
+		Name:           name,
+		Skin:           skin,
+		Color:          playerColor(playerID),
+		Team:           team,
+		LastStand:      1,
+		Points:         config.StartingPoints,
+		Attrs:          skinAttributes(skin),
+		Binary:         encoding == encodingBinary,
+		DTLS:           hasDTLSSession(addr),
+		Token:          token,
+		Ping:           -1,
+		HP:             config.MaxHP,
+		LastSeen:       now,
+		LastActivity:   now,
+		LastMoveTime:   now,
+		ProtectedUntil: now.Add(time.Duration(config.SpawnProtection)),
+	}
+	players[playerID] = player
+	clientAddrs[playerID] = addr // Сохраняем адрес клиента
+	trackConnection(addr)
+	addrPlayers[addr.String()] = append(addrPlayers[addr.String()], playerID)
+	metrics.joins.Add(1)
+	log.Printf("Игрок %d подключился", playerID)
+
+	broadcastReliable(map[string]interface{}{
+		"type":  "event",
+		"event": "join",
+		"player": map[string]interface{}{
+			"id":    player.ID,
+			"name":  player.Name,
+			"skin":  player.Skin,
+			"color": player.Color,
+			"team":  player.Team,
+		},
+	}, playerID)
+
+	// Отправляем присвоенный ID, точку появления, карту и полное состояние игры,
+	// чтобы клиент мог отрисовать сцену, не дожидаясь рассылки из gameLoop
+	response := map[string]interface{}{
+		"type":        "joined",
+		"id":          playerID,
+		"serverEpoch": serverEpoch,
+		"encoding":    encoding,
+		"spawn": map[string]interface{}{
+			"x": player.X,
+			"y": player.Y,
+		},
+		"map":     mapInfo(),
+		"state":   currentGameState(true),
+		"status":  matchStatus(),
+		"actions": actionInfo(player, now),
+	}
+	sendUDPMessage(addr, response)
 }
 
 func sendUDPMessage(addr *net.UDPAddr, msg map[string]interface{}) {
 	data, err := json.Marshal(msg)
 	if err != nil {
-		log.Println("Ошибка сериализации сообщения:", err)
+		recordMarshalError("сообщения", err)
 		return
 	}
-	_, err = conn.WriteToUDP(data, addr)
+	writeUDP(addr, data)
+}
+
+// writeUDP отправляет уже сериализованное сообщение клиенту
+func writeUDP(addr *net.UDPAddr, data []byte) {
+	err := writeTo(data, addr)
 	if err != nil {
 		log.Println("Ошибка отправки сообщения клиенту:", err)
 	}
 }
 
-func handleAction(player *Player, action string) {
-	currentTime := time.Now()
-	cooldown := 2 * time.Second
+// hasPosition проверяет, содержит ли сообщение позицию игрока
+func hasPosition(msg map[string]interface{}) bool {
+	_, hasX := msg["x"]
+	_, hasY := msg["y"]
+	return hasX || hasY
+}
+
+// isFinite проверяет, что число не NaN и не бесконечность
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// sameAddr сравнивает UDP-адреса по IP и порту
+func sameAddr(a, b *net.UDPAddr) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return a.Port == b.Port && a.IP.Equal(b.IP)
+}
+
+// ownsPlayer проверяет привязку адреса к игроку в обе стороны, вызывается под mutex
+func ownsPlayer(addr *net.UDPAddr, playerID int) bool {
+	return sameAddr(clientAddrs[playerID], addr) && slices.Contains(addrPlayers[addr.String()], playerID)
+}
+
+// normalizeAngle приводит угол в радианах к диапазону [-π, π]
+func normalizeAngle(angle float64) float64 {
+	return math.Remainder(angle, 2*math.Pi)
+}
+
+// sendToPlayer отправляет сообщение игроку по сохранённому адресу, вызывается под mutex
+func sendToPlayer(playerID int, msg map[string]interface{}) {
+	if addr, ok := clientAddrs[playerID]; ok {
+		sendUDPMessage(addr, msg)
+	}
+}
+
+// logDebug пишет сообщение в лог, только если включён режим отладки
+func logDebug(format string, args ...interface{}) {
+	if debugLogging {
+		log.Printf(format, args...)
+	}
+}
+
+func handleAction(player *Player, action string, msg map[string]interface{}) {
+	currentTime := clock()
 
 	switch action {
 	case "push":
-		if currentTime.Sub(player.LastPushTime) > cooldown {
+		if actionReady(player, player.LastPushTime, config.PushCooldown, currentTime) {
 			player.LastPushTime = currentTime
+			player.LastActionTime = currentTime
+			breakProtection(player)
 			log.Printf("Игрок %d использовал push", player.ID)
 			applyPush(player)
 		}
 	case "pull":
-		if currentTime.Sub(player.LastPullTime) > cooldown {
+		if actionReady(player, player.LastPullTime, config.PullCooldown, currentTime) {
 			player.LastPullTime = currentTime
+			player.LastActionTime = currentTime
+			breakProtection(player)
 			log.Printf("Игрок %d использовал pull", player.ID)
 			applyPull(player)
 		}
+	case "swap":
+		if actionReady(player, player.LastSwapTime, config.SwapCooldown, currentTime) {
+			player.LastSwapTime = currentTime
+			player.LastActionTime = currentTime
+			breakProtection(player)
+			log.Printf("Игрок %d использовал swap", player.ID)
+			applySwap(player)
+		}
+	case "freeze":
+		if actionReady(player, player.LastFreezeTime, config.FreezeCooldown, currentTime) {
+			player.LastFreezeTime = currentTime
+			player.LastActionTime = currentTime
+			breakProtection(player)
+			log.Printf("Игрок %d использовал freeze", player.ID)
+			applyFreeze(player, currentTime)
+		}
+	case "dash":
+		if actionReady(player, player.LastDashTime, config.DashCooldown, currentTime) {
+			player.LastDashTime = currentTime
+			player.LastActionTime = currentTime
+			log.Printf("Игрок %d использовал dash", player.ID)
+			applyDash(player, currentTime)
+		}
+	case "emote":
+		handleEmote(player, msg)
+	case "status":
+		status := matchStatus()
+		status["actions"] = actionInfo(player, currentTime)
+		sendToPlayer(player.ID, status)
+	case "resync":
+		// Полное состояние по запросу клиента, не чаще раза в resyncCooldown
+		if currentTime.Sub(player.LastResyncTime) < resyncCooldown {
+			logDebug("Игрок %d слишком часто запрашивает полное состояние", player.ID)
+			break
+		}
+		player.LastResyncTime = currentTime
+		state := currentGameState(true)
+		sendToPlayer(player.ID, map[string]interface{}{
+			"type":  "resync",
+			"state": state,
+		})
+		releasePlayersState(state.Players)
+	case "players":
+		sendToPlayer(player.ID, map[string]interface{}{
+			"type":    "players",
+			"players": playersList(),
+		})
+	case "map":
+		// Статическая геометрия запрашивается один раз, а не в каждом снимке
+		sendToPlayer(player.ID, map[string]interface{}{
+			"type": "map",
+			"map":  mapInfo(),
+		})
+	case "leaderboard":
+		sendToPlayer(player.ID, map[string]interface{}{
+			"type":    "leaderboard",
+			"entries": topLeaderboard(config.LeaderboardSize),
+		})
+	default:
+		// Сообщаем клиенту об ошибке, чтобы опечатки в действиях не терялись молча
+		logDebug("Игрок %d прислал неизвестное действие %q", player.ID, action)
+		sendToPlayer(player.ID, map[string]interface{}{
+			"error":  "unknown_action",
+			"action": action,
+		})
 	}
 }
-func sendGameState(addr *net.UDPAddr) {
-	mutex.Lock()
-	defer mutex.Unlock()
 
-	gameState := GameState{
-		Players:       getPlayersState(),
-		CapturePoints: capturePoints,
+// allowAction проверяет частоту действий игрока. Эмоции ограничиваются
+// отдельно от игровых действий. Вызывается под mutex
+func allowAction(player *Player, action string) bool {
+	if action == "emote" {
+		return allowMessage(player, &player.ChatBucket, config.ChatRateLimit, "chat", player.LastSeen)
 	}
+	return allowMessage(player, &player.ActionBucket, config.ActionRateLimit, "action", player.LastSeen)
+}
 
-	data, err := json.Marshal(gameState)
-	if err != nil {
-		log.Println("Ошибка при сериализации состояния игры:", err)
-		return
+// Как часто клиент может запрашивать полное состояние
+const resyncCooldown = time.Second
+
+// Сколько последних номеров действий помнится для отсева дубликатов
+const actionSeqWindow = 32
+
+// isDuplicateAction проверяет, не обрабатывалось ли уже действие с тем же
+// "seq". UDP может продублировать пакет, а повторный push, в отличие от
+// повторного перемещения, не безобиден. Действия без seq не отсеиваются.
+// Вызывается под mutex
+func isDuplicateAction(player *Player, msg map[string]interface{}) bool {
+	seq, ok := msg["seq"].(float64)
+	if !ok {
+		return false
+	}
+	if slices.Contains(player.RecentActionSeqs, int(seq)) {
+		logDebug("Игрок %d прислал повтор действия с seq %d", player.ID, int(seq))
+		return true
 	}
+	if len(player.RecentActionSeqs) >= actionSeqWindow {
+		player.RecentActionSeqs = player.RecentActionSeqs[1:]
+	}
+	player.RecentActionSeqs = append(player.RecentActionSeqs, int(seq))
+	return false
+}
 
-	// Проверка, что адрес клиента существует в клиентских адресах
-	if addr == nil {
-		log.Println("Ошибка: адрес клиента nil")
-		return
+// isOffensiveAction проверяет, воздействует ли действие на других игроков
+func isOffensiveAction(action string) bool {
+	switch action {
+	case "push", "pull", "swap", "freeze":
+		return true
 	}
+	return false
+}
 
-	_, err = conn.WriteToUDP(data, addr)
-	if err != nil {
-		log.Println("Ошибка при отправке состояния игры:", err)
+// isGameAction проверяет, относится ли действие к игре, а не к запросам и
+// общению: атакующие действия и рывок. На паузе они отклоняются
+func isGameAction(action string) bool {
+	return isOffensiveAction(action) || action == "dash"
+}
+
+// breakProtection снимает защиту после появления с игрока, применившего
+// атакующее действие: неуязвимый игрок не может безнаказанно нападать.
+// Нейтральные действия (emote, status и т.п.) защиту не снимают. Вызывается под mutex
+func breakProtection(player *Player) {
+	if config.AttackBreaksProtection {
+		player.ProtectedUntil = time.Time{}
 	}
 }
-func applyPush(player *Player) {
-	// Ищем ближайшего игрока
+
+// actionReady проверяет перезарядку действия и общую перезарядку GlobalCooldown
+// после любого действия, вызывается под mutex
+func actionReady(player *Player, last time.Time, cooldown Duration, now time.Time) bool {
+	if now.Sub(player.LastActionTime) < time.Duration(config.GlobalCooldown) {
+		return false
+	}
+	return now.Sub(last) > actionCooldown(player, cooldown, now)
+}
+
+// actionInfo описывает дальность и перезарядку действий игрока, чтобы клиент
+// рисовал индикаторы по тем же значениям, что использует сервер. Перезарядка
+// учитывает действующее усиление cooldown. Вызывается под mutex
+func actionInfo(player *Player, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"push": map[string]interface{}{
+			"range":    config.PushRange,
+			"cooldown": actionCooldown(player, config.PushCooldown, now).Seconds(),
+		},
+		"pull": map[string]interface{}{
+			"range":    config.PullRange,
+			"cooldown": actionCooldown(player, config.PullCooldown, now).Seconds(),
+		},
+		"swap": map[string]interface{}{
+			"range":    config.SwapRange,
+			"cooldown": actionCooldown(player, config.SwapCooldown, now).Seconds(),
+		},
+		"freeze": map[string]interface{}{
+			"range":    config.FreezeRadius,
+			"cooldown": actionCooldown(player, config.FreezeCooldown, now).Seconds(),
+		},
+		"dash": map[string]interface{}{
+			"range":    config.DashDistance,
+			"cooldown": actionCooldown(player, config.DashCooldown, now).Seconds(),
+		},
+	}
+}
+
+// findPlayersInRange возвращает по возрастанию ID всех игроков в пределах
+// maxDistance от player, до которых не мешают дотянуться препятствия.
+// Защищённые игроки не выбираются. Вызывается под mutex
+func findPlayersInRange(player *Player, maxDistance float64) []*Player {
+	var result []*Player
+	now := clock()
+	for _, p := range players {
+		if p.ID == player.ID || isProtected(p, now) {
+			continue
+		}
+		if math.Hypot(player.X-p.X, player.Y-p.Y) >= maxDistance {
+			continue
+		}
+		if isLineOfSightBlocked(player.X, player.Y, p.X, p.Y) {
+			continue
+		}
+		result = append(result, p)
+	}
+	slices.SortFunc(result, func(a, b *Player) int { return a.ID - b.ID })
+	return result
+}
+
+// findClosestPlayer ищет ближайшего к player игрока в пределах maxDistance,
+// до которого не мешают дотянуться препятствия. При arc > 0 цель ищется только
+// в секторе шириной arc по направлению взгляда Facing
+func findClosestPlayer(player *Player, maxDistance, arc float64) (*Player, float64) {
 	var closestPlayer *Player
 	closestDistance := math.MaxFloat64
 
 	for _, p := range players {
-		if p.ID != player.ID {
-			distance := math.Sqrt(math.Pow(player.X-p.X, 2) + math.Pow(player.Y-p.Y, 2))
-			if distance < closestDistance {
-				closestDistance = distance
-				closestPlayer = p
-			}
+		// Защищённые после появления игроки неуязвимы
+		if p.ID == player.ID || isProtected(p, clock()) {
+			continue
+		}
+		distance := math.Sqrt(math.Pow(player.X-p.X, 2) + math.Pow(player.Y-p.Y, 2))
+		if distance >= maxDistance || distance > closestDistance {
+			continue
+		}
+		// При равном расстоянии выбирается игрок с меньшим ID, чтобы исход не
+		// зависел от порядка обхода карты
+		if distance == closestDistance && closestPlayer != nil && p.ID > closestPlayer.ID {
+			continue
+		}
+		if arc > 0 && distance > 0 && math.Abs(normalizeAngle(math.Atan2(p.Y-player.Y, p.X-player.X)-player.Facing)) > arc/2 {
+			continue
+		}
+		// Цель за препятствием недостижима
+		if isLineOfSightBlocked(player.X, player.Y, p.X, p.Y) {
+			continue
 		}
+		closestDistance = distance
+		closestPlayer = p
 	}
 
-	if closestPlayer != nil && closestDistance < 100 { // Проверка дистанции
-		// Рассчитываем вектор отталкивания
+	return closestPlayer, closestDistance
+}
+
+func applyPush(player *Player) {
+	// Ищем ближайшего игрока в зоне видимости
+	closestPlayer, closestDistance := findClosestPlayer(player, config.PushRange, config.PushArc)
+
+	// На нулевой дистанции направление не определено
+	if closestPlayer != nil && closestDistance > 0 {
+		// Рассчитываем вектор отталкивания. Направленный толчок (PushArc)
+		// отбрасывает цель по направлению взгляда толкнувшего
 		dx := closestPlayer.X - player.X
 		dy := closestPlayer.Y - player.Y
-		length := math.Sqrt(dx*dx + dy*dy)
-		if length != 0 {
-			dx /= length
-			dy /= length
+		dx /= closestDistance
+		dy /= closestDistance
+		if config.PushArc > 0 {
+			dx, dy = math.Cos(player.Facing), math.Sin(player.Facing)
 		}
 
-		// Определяем силу отталкивания
-		pushStrength := 1000.0
-		distance := closestDistance // Используем найденную дистанцию
-
-		// Применяем отталкивание с плавным перемещением
-		go func() {
-			steps := 10                    // Количество шагов для плавного перемещения
-			delay := 16 * time.Millisecond // Задержка между шагами
-
-			for i := 0; i < steps; i++ {
-				mutex.Lock()
-
-				// Обновляем позицию
-				closestPlayer.X += (dx / distance) * pushStrength / float64(steps)
-				closestPlayer.Y += (dy / distance) * pushStrength / float64(steps)
-
-				mutex.Unlock()
-				time.Sleep(delay)
-			}
-		}()
+		// Чем ближе цель, тем сильнее отталкивание
+		if !applyKnockback(closestPlayer, dx, dy, config.PushStrength/closestDistance) {
+			return
+		}
+		// Отдача отбрасывает толкнувшего в обратную сторону
+		if config.PushRecoil > 0 {
+			applyKnockback(player, -dx, -dy, config.PushRecoil*config.PushStrength/closestDistance)
+		}
 
+		player.Stats.PushesLanded++
+		metrics.pushes.Add(1)
 		log.Printf("Игрок %d оттолкнул игрока %d", player.ID, closestPlayer.ID)
 	}
 }
 
 func applyPull(player *Player) {
-	// Ищем ближайшего игрока
-	var closestPlayer *Player
-	closestDistance := math.MaxFloat64
-
-	for _, p := range players {
-		if p.ID != player.ID {
-			distance := math.Sqrt(math.Pow(player.X-p.X, 2) + math.Pow(player.Y-p.Y, 2))
-			if distance < closestDistance {
-				closestDistance = distance
-				closestPlayer = p
-			}
-		}
-	}
+	// Ищем ближайшего игрока в зоне видимости
+	closestPlayer, closestDistance := findClosestPlayer(player, config.PullRange, 0)
 
-	if closestPlayer != nil && closestDistance < 100 { // Проверка дистанции
+	// На нулевой дистанции направление не определено
+	if closestPlayer != nil && closestDistance > 0 {
 		// Рассчитываем вектор притяжения
 		dx := player.X - closestPlayer.X
 		dy := player.Y - closestPlayer.Y
-		length := math.Sqrt(dx*dx + dy*dy)
-		if length != 0 {
-			dx /= length
-			dy /= length
-		}
+		dx /= closestDistance
+		dy /= closestDistance
 
-		// Определяем силу притяжения
-		pullStrength := 1000.0
-		distance := closestDistance
+		// Чем ближе цель, тем сильнее притяжение
+		if !applyKnockback(closestPlayer, dx, dy, config.PullStrength/closestDistance) {
+			return
+		}
 
-		// Применяем плавное притяжение
-		go func() {
-			steps := 10                    // Количество шагов для плавного перемещения
-			delay := 16 * time.Millisecond // Задержка между шагами
+		player.Stats.PullsLanded++
+		metrics.pulls.Add(1)
+		log.Printf("Игрок %d притянул игрока %d", player.ID, closestPlayer.ID)
+	}
+}
 
-			for i := 0; i < steps; i++ {
-				mutex.Lock()
+// applySwap мгновенно меняет местами игрока и ближайшего к нему соперника
+func applySwap(player *Player) {
+	target, _ := findClosestPlayer(player, config.SwapRange, 0)
+	if target == nil {
+		return
+	}
 
-				// Обновляем позицию
-				closestPlayer.X += (dx / distance) * pullStrength / float64(steps)
-				closestPlayer.Y += (dy / distance) * pullStrength / float64(steps)
+	// Ожидающий ввод вернул бы игроков на прежние места
+	player.InputQueue = nil
+	target.InputQueue = nil
+	player.X, target.X = target.X, player.X
+	player.Y, target.Y = target.Y, player.Y
 
-				mutex.Unlock()
-				time.Sleep(delay)
-			}
-		}()
+	broadcastEvent(map[string]interface{}{
+		"type": "swap",
+		"from": player.ID,
+		"to":   target.ID,
+	})
+	log.Printf("Игрок %d поменялся местами с игроком %d", player.ID, target.ID)
+}
 
-		log.Printf("Игрок %d притянул игрока %d", player.ID, closestPlayer.ID)
+// applyFreeze замораживает всех остальных игроков в радиусе FreezeRadius на
+// FreezeDuration. Замороженные не смещаются. Вызывается под mutex
+func applyFreeze(player *Player, now time.Time) {
+	targets := findPlayersInRange(player, config.FreezeRadius)
+	frozen := make([]int, 0, len(targets))
+	for _, target := range targets {
+		target.FrozenUntil = now.Add(time.Duration(config.FreezeDuration))
+		target.InputQueue = nil
+		frozen = append(frozen, target.ID)
 	}
+	if len(frozen) == 0 {
+		return
+	}
+
+	broadcastEvent(map[string]interface{}{
+		"type":     "freeze",
+		"from":     player.ID,
+		"targets":  frozen,
+		"duration": time.Duration(config.FreezeDuration).Seconds(),
+	})
+	log.Printf("Игрок %d заморозил игроков %v", player.ID, frozen)
 }
 
 func gameLoop() {
-	for {
-		time.Sleep(10 * time.Millisecond)
+	tick := 10 * time.Millisecond
+	lastTick := clock()
+	var lastKeyframe time.Time
+	for waitTick(tick) {
 		mutex.Lock()
 
-		gameState := GameState{
-			Players:       getPlayersState(),
-			CapturePoints: capturePoints,
+		now := clock()
+		// Ключевой кадр рассылается раз в KeyframeInterval
+		keyframe := now.Sub(lastKeyframe) >= time.Duration(config.KeyframeInterval)
+		if keyframe {
+			lastKeyframe = now
 		}
+		gameTick(now, now.Sub(lastTick), keyframe)
+		lastTick = now
 
-		// Отправка состояния игры всем игрокам
-		for id, player := range players {
-			data, err := json.Marshal(gameState)
-			if err != nil {
-				log.Println("Ошибка при сериализации состояния игры:", err)
-				continue
-			}
+		// Частота тиков может измениться при перезагрузке конфигурации
+		if interval := time.Duration(config.TickInterval); interval > 0 {
+			tick = interval
+		}
+		mutex.Unlock()
+	}
+}
 
-			// Пример использования переменной player
-			log.Printf("Отправка состояния игры игроку %d, координаты: (%.2f, %.2f,%s)", player.ID, player.X, player.Y, player.FlipX)
+// gameTick выполняет один игровой тик: продвигает движение и отброс и
+// рассылает состояние игрокам и зрителям. Не зависит от таймера gameLoop,
+// поэтому тик можно вызвать напрямую, например для замера его стоимости.
+// Вызывается под mutex
+func gameTick(now time.Time, dt time.Duration, keyframe bool) {
+	// Отброс интегрируется по фактически прошедшему времени dt. На паузе
+	// игроки стоят на месте, в том числе отброшенные, а усиления не истекают
+	if matchPhase != phasePaused {
+		drainInputQueues()
+		integrateKnockback(now, dt)
+		expireBuffs(now)
+	}
+	checkZoneExits(now)
+	retransmitReliable(now)
+	if config.CooldownsInState == cooldownsOwn {
+		for _, player := range players {
+			sendCooldowns(player, now)
+		}
+	}
 
-			// Отправляем состояние игры игроку по его адресу
+	// Состояние сериализуется один раз за тик в буфер из пула
+	gameState := currentGameState(keyframe)
+	enc := encoderPool.Get().(*stateEncoder)
+	data, err := enc.encode(gameState)
+	binaryData = encodePositions(binaryData[:0], gameState.Players)
+	releasePlayersState(gameState.Players)
+	if err != nil {
+		recordMarshalError("состояния игры", err)
+	} else {
+		// Отправка состояния игры всем игрокам
+		for id, player := range players {
+			// Отправляем состояние игры игроку по его адресу. WriteToUDP
+			// синхронный, поэтому буфер можно вернуть в пул после цикла
 			if addr, ok := clientAddrs[id]; ok {
-				_, err = conn.WriteToUDP(data, addr)
+				// Двоичные клиенты получают позиции каждый тик, а полное
+				// JSON-состояние — только в ключевых кадрах
+				if player.Binary {
+					err = writeTo(binaryData, addr)
+					if err == nil && keyframe {
+						err = writeTo(data, addr)
+					}
+				} else {
+					err = writeTo(data, addr)
+				}
 				if err != nil {
 					log.Println("Ошибка при отправке состояния игроку:", err)
+					if unreachableError(err) {
+						recordWriteFailure(id)
+					}
+				} else {
+					delete(writeFailures, id)
 				}
 			}
 		}
 
-		mutex.Unlock()
+		// Зрители получают ту же рассылку
+		for _, spectator := range spectators {
+			err = writeTo(data, spectator.Addr)
+			if err != nil {
+				log.Println("Ошибка при отправке состояния зрителю:", err)
+			}
+		}
+	}
+	encoderPool.Put(enc)
+}
+
+// newServerEpoch выбирает эпоху сервера. Генератор rng не подходит: при
+// одинаковом Seed он дал бы ту же эпоху после перезапуска
+func newServerEpoch() uint32 {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return uint32(time.Now().UnixNano())
+	}
+	return binary.LittleEndian.Uint32(b[:])
+}
+
+// currentGameState собирает текущее состояние игры. Препятствия статичны и
+// передаются только в ключевых кадрах. Вызывается под mutex
+func currentGameState(keyframe bool) GameState {
+	state := GameState{
+		Players:       getPlayersState(),
+		CapturePoints: getCapturePointsState(),
+		Keyframe:      keyframe,
+		Epoch:         serverEpoch,
+	}
+	if keyframe {
+		state.Obstacles = config.Obstacles
+	}
+	return state
+}
+
+// mapInfo описывает статическую геометрию карты, вызывается под mutex
+func mapInfo() map[string]interface{} {
+	points := make([]map[string]interface{}, 0, len(capturePoints))
+	for _, cp := range capturePoints {
+		points = append(points, map[string]interface{}{
+			"id":              cp.ID,
+			"x":               cp.X,
+			"y":               cp.Y,
+			"radius":          cp.Radius,
+			"scoreWeight":     cp.ScoreWeight,
+			"requiredPlayers": max(cp.RequiredPlayers, 1),
+		})
+	}
+	return map[string]interface{}{
+		"name":          config.Map,
+		"width":         config.WorldWidth,
+		"height":        config.WorldHeight,
+		"obstacles":     config.Obstacles,
+		"spawnPoints":   config.SpawnPoints,
+		"teamSpawns":    config.TeamSpawns,
+		"capturePoints": points,
 	}
 }
 
+// getPlayersState копирует игроков в срез из пула; после сериализации
+// срез следует вернуть через releasePlayersState
 func getPlayersState() []Player {
-	var playersState []Player
+	playersState := *playersPool.Get().(*[]Player)
+	now := clock()
 	for _, player := range players {
-		playersState = append(playersState, *player)
+		state := *player
+		state.Protected = isProtected(player, now)
+		state.Frozen = max(player.FrozenUntil.Sub(now), 0).Seconds()
+		state.Buffs = buffsState(player, now)
+		state.PushCooldown = cooldownLeft(player, player.LastPushTime, config.PushCooldown, now).Seconds()
+		state.PullCooldown = cooldownLeft(player, player.LastPullTime, config.PullCooldown, now).Seconds()
+		if config.CooldownsInState == cooldownsAll {
+			state.Cooldowns = remainingCooldowns(player, now)
+		}
+		playersState = append(playersState, state)
 	}
+	// Порядок обхода карты случаен; упорядоченный снимок повторяется при
+	// одинаковом зерне и часах
+	slices.SortFunc(playersState, func(a, b Player) int { return a.ID - b.ID })
 	return playersState
 }
 
+// getCapturePointsState копирует точки захвата по значению, чтобы снимок не
+// зависел от последующих изменений точек. Вызывается под mutex
+func getCapturePointsState() []CapturePoint {
+	now := clock()
+	state := make([]CapturePoint, len(capturePoints))
+	copy(state, capturePoints)
+	for i := range state {
+		// Карта находящихся в зоне игроков не сериализуется и не должна
+		// разделяться между снимком и игрой
+		state[i].Occupants = nil
+		state[i].Contributors = nil
+
+		// Клиенту не нужно искать владельца в списке игроков, чтобы окрасить точку.
+		// Если владелец ушёл, точка окрашивается нейтральным цветом
+		state[i].OwnerColor = neutralColor
+		if owner, ok := players[state[i].CapturingPlayer]; ok && state[i].IsCaptured {
+			state[i].OwnerColor = owner.Color
+		}
+		setCaptureTimers(&state[i], captureClock(now))
+	}
+	return state
+}
+
 func checkCapturePoints() {
 	for {
 		mutex.Lock()
 
-		// Логика захвата точек
-		for i := range capturePoints {
-			cp := &capturePoints[i]
-
-			// Считаем, кто находится в зоне захвата
-			var capturingPlayer *Player
-			for _, player := range players {
-				if isPlayerInZone(player, cp) {
-					if capturingPlayer == nil {
-						capturingPlayer = player
-					} else {
-						// Если больше одного игрока в зоне, сбрасываем захват
-						capturingPlayer = nil
-						cp.EnterTime = time.Time{} // Сброс таймера
-						break
-					}
+		updateMatch(clock())
+		// После окончания матча и во время ожидания игроков точки не
+		// захватываются и очки не начисляются
+		if matchActive() {
+			updateCapturePoints()
+		}
+
+		mutex.Unlock()
+		if !waitTick(100 * time.Millisecond) { // Задержка между проверками
+			return
+		}
+	}
+}
+
+// updateCapturePoints продвигает захват точек и начисляет очки, вызывается под mutex
+func updateCapturePoints() {
+	now := clock()
+	updateRotation(now)
+	updateLastStand(now)
+
+	// Логика захвата точек
+	for i := range capturePoints {
+		cp := &capturePoints[i]
+
+		// События входа и выхода из зоны, в том числе после отталкивания
+		updateZoneOccupants(cp, now)
+
+		// Неактивную точку нельзя захватить, и очков она не приносит
+		if !cp.Active {
+			continue
+		}
+		trackContributors(cp, now)
+
+		// Считаем, кто находится в зоне захвата
+		capturingPlayer, contested := zoneCapturer(cp, now)
+		if contested {
+			// Если больше одного игрока в зоне, сбрасываем захват
+			cp.EnterTime = time.Time{} // Сброс таймера
+			cp.CurrentCapturingPlayer = 0
+			cp.PausedAt = time.Time{}
+		}
+
+		// Если только один игрок в зоне, продолжаем захват
+		if capturingPlayer != nil {
+			// Игроков снова хватает: замерший захват продолжается с того же места
+			if !cp.PausedAt.IsZero() {
+				if !cp.EnterTime.IsZero() {
+					cp.EnterTime = cp.EnterTime.Add(now.Sub(cp.PausedAt))
 				}
+				cp.PausedAt = time.Time{}
 			}
-
-			// Если только один игрок в зоне, продолжаем захват
-			if capturingPlayer != nil {
-				cp.CurrentCapturingPlayer = capturingPlayer.ID
-				if cp.EnterTime.IsZero() {
-					cp.EnterTime = time.Now()
+			// Захватчик сменился между проверками (например, прежнего вытолкнули
+			// из зоны): прогресс прежнего захватчика новому не засчитывается.
+			// Точку для отряда захватывает команда, поэтому смена игрока, от
+			// имени которого ведётся захват, прогресс не сбрасывает
+			if cp.RequiredPlayers > 1 {
+				if cp.CaptureTeam != capturingPlayer.Team {
+					cp.CaptureTeam = capturingPlayer.Team
+					cp.EnterTime = time.Time{}
 				}
-				if time.Since(cp.EnterTime) >= 5*time.Second {
-					if !cp.IsCaptured || cp.CapturingPlayer != capturingPlayer.ID {
-						cp.IsCaptured = true
-						cp.CapturingPlayer = capturingPlayer.ID
-						cp.CaptureStart = time.Now()
-						cp.EnterTime = time.Time{} // Сброс таймера захвата
+			} else if cp.CurrentCapturingPlayer != capturingPlayer.ID {
+				cp.EnterTime = time.Time{}
+			}
+			cp.CurrentCapturingPlayer = capturingPlayer.ID
+			if cp.EnterTime.IsZero() {
+				cp.EnterTime = clock()
+			}
+			if clock().Sub(cp.EnterTime) >= captureTime {
+				if !cp.IsCaptured || cp.CapturingPlayer != capturingPlayer.ID {
+					cp.IsCaptured = true
+					cp.CapturingPlayer = capturingPlayer.ID
+					cp.OwnerTeam = capturingPlayer.Team
+					cp.CaptureStart = clock()
+					cp.EnterTime = time.Time{} // Сброс таймера захвата
+					cp.HoldStart = clock()     // Новый владелец начинает серию заново
+					cp.StreakMultiplier = 1
+					capturingPlayer.Stats.Captures++
+					metrics.captures.Add(1)
+					creditAssists(cp, capturingPlayer.ID, now)
+					audit("capture", map[string]interface{}{
+						"point":  cp.ID,
+						"player": auditPlayer(capturingPlayer),
+					})
+					enforceOwnedPointsCap(capturingPlayer.ID, cp)
+
+					// Захват под носом у противника приносит бонус
+					bonus := 0
+					contested := config.ContestedCaptureBonus > 0 && enemiesNear(cp, capturingPlayer, config.ContestedCaptureRadius, now) > 0
+					if contested {
+						bonus = config.ContestedCaptureBonus
+					}
+					broadcastEvent(map[string]interface{}{
+						"type":      "capture",
+						"event":     "captured",
+						"point":     cp.ID,
+						"player":    capturingPlayer.ID,
+						"contested": contested,
+						"bonus":     bonus,
+					})
+					if contested {
+						audit("score", map[string]interface{}{
+							"point":  cp.ID,
+							"player": auditPlayer(capturingPlayer),
+							"points": bonus,
+							"reason": "contested_capture",
+						})
+						awardPoints(capturingPlayer, bonus)
 					}
 				}
-			} else {
-				// Никто не захватывает, сбрасываем таймер
-				cp.EnterTime = time.Time{}
-				cp.CurrentCapturingPlayer = 0
 			}
+		} else if !contested && understaffed(cp, now) {
+			// Команде не хватает игроков: прогресс захвата замирает
+			if cp.PausedAt.IsZero() {
+				cp.PausedAt = now
+			}
+		} else if cp.CurrentCapturingPlayer != 0 && !cp.PausedAt.IsZero() && now.Sub(cp.PausedAt) < time.Duration(config.CaptureGrace) {
+			// Захватчик вышел из зоны: прогресс замер и ждёт его возвращения
+		} else {
+			// Никто не захватывает, сбрасываем таймер
+			cp.EnterTime = time.Time{}
+			cp.CurrentCapturingPlayer = 0
+			cp.PausedAt = time.Time{}
+		}
+
+		// Слишком долго удерживаемая одним владельцем точка снова становится
+		// нейтральной и должна быть захвачена заново
+		if cp.IsCaptured && cp.MaxHold > 0 && now.Sub(cp.HoldStart) >= cp.MaxHold {
+			log.Printf("Точка %d удерживалась игроком %d дольше %v и стала нейтральной", cp.ID, cp.CapturingPlayer, cp.MaxHold)
+			neutralizePoint(cp, "hold_cap")
+			continue
+		}
 
-			// Начисление очков за захваченные точки
-			if cp.IsCaptured {
-				// Проверяем, сколько времени точка удерживается и начисляем очки
-				if time.Since(cp.CaptureStart) >= 5*time.Second {
-					if cp.CapturingPlayer != 0 {
-						player := players[cp.CapturingPlayer]
+		// Точка, с которой ушёл владелец, постепенно становится нейтральной
+		if cp.IsCaptured && drainPoint(cp, now) {
+			continue
+		}
 
-						// Начисляем очки захватчику
-						player.Points++ // Начисляем очки игроку
+		// В режиме "царь горы" владелец, покинувший точку, сразу перестаёт
+		// получать очки, а неполный интервал начисления сгорает
+		if cp.IsCaptured && !holderScores(cp) {
+			cp.CaptureStart = now
+			cp.HoldStart = now
+			cp.StreakMultiplier = 1
+			continue
+		}
 
-						// Обновляем время последнего начисления очков
-						cp.CaptureStart = time.Now()
-					}
+		// Начисление очков за захваченные точки
+		if cp.IsCaptured {
+			cp.StreakMultiplier = streakMultiplier(clock().Sub(cp.HoldStart))
+
+			// Проверяем, сколько времени точка удерживается и начисляем очки
+			if clock().Sub(cp.CaptureStart) >= scoreInterval {
+				if player := players[cp.CapturingPlayer]; player != nil && player.Team != cp.OwnerTeam {
+					// Владелец сменил команду в обход setPlayerTeam: точка не
+					// должна приносить очки новой команде за чужой захват
+					neutralizePoint(cp, "team_change")
+					continue
+				} else if player != nil && isAFK(player, now) {
+					// Бездействующий владелец не получает очков, пока снова не
+					// начнёт двигаться, и пропущенные интервалы не копятся
+					cp.CaptureStart = now
+				} else if cp.CapturingPlayer != 0 {
+					// Начисляем очки захватчику с учётом серии удержания и
+					// "последнего рубежа"
+					points := cp.ScoreWeight * cp.StreakMultiplier * player.LastStand
+					audit("score", map[string]interface{}{
+						"point":  cp.ID,
+						"player": auditPlayer(player),
+						"points": points,
+					})
+					awardPoints(player, points)
+
+					// Обновляем время последнего начисления очков
+					cp.CaptureStart = clock()
 				}
 			}
 		}
+	}
 
-		mutex.Unlock()
-		time.Sleep(100 * time.Millisecond) // Задержка между проверками
+	updateTimeOnPoint(now)
+}
+
+// streakMultiplier возвращает множитель очков для точки, удерживаемой в течение held
+func streakMultiplier(held time.Duration) int {
+	step := time.Duration(config.StreakStep)
+	if step <= 0 {
+		return 1
+	}
+	multiplier := 1 + int(held/step)
+	if config.MaxStreakMultiplier > 0 && multiplier > config.MaxStreakMultiplier {
+		multiplier = config.MaxStreakMultiplier
 	}
+	return multiplier
 }
+
+// isPlayerInZone проверяет, стоит ли игрок на точке по правилу CaptureRule
 func isPlayerInZone(player *Player, cp *CapturePoint) bool {
 	if player == nil {
 		return false
 	}
 	distance := math.Sqrt(math.Pow(player.X-cp.X, 2) + math.Pow(player.Y-cp.Y, 2))
-	return distance <= cp.Radius
+	switch config.CaptureRule {
+	case captureRuleOverlap:
+		return distance <= cp.Radius+config.PlayerRadius
+	case captureRuleInside:
+		return distance+config.PlayerRadius <= cp.Radius
+	default:
+		return distance <= cp.Radius
+	}
 }
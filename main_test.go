@@ -0,0 +1,579 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Сколько тест ждёт сообщения от сервера
+const testTimeout = 2 * time.Second
+
+func TestMain(m *testing.M) {
+	// Сервер пишет в лог о каждом событии, в тестах это только шум
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// resetGame возвращает глобальное состояние сервера к только что запущенному
+// с конфигурацией по умолчанию и открывает сокет сервера на свободном порту
+func resetGame(t testing.TB) {
+	t.Helper()
+
+	config = defaultConfig()
+	configPath = ""
+	mapFlag = ""
+	pendingMap = nil
+	players = make(map[int]*Player)
+	clientAddrs = make(map[int]*net.UDPAddr)
+	addrPlayers = make(map[string][]int)
+	writeFailures = make(map[int]int)
+	nextPlayerID = 0
+	spectators = make(map[string]*Spectator)
+	waitQueue = nil
+	reliableQueues = make(map[int]map[int]*pendingMessage)
+	nextReliableSeq = make(map[int]int)
+	leaderboard = make(map[string]*LeaderboardEntry)
+	pausedPhase, pausedAt, adminPausedAt, matchEndedAt = "", time.Time{}, time.Time{}, time.Time{}
+	auditEntries, auditDone, auditDropped = nil, nil, 0
+	binaryData = nil
+	debugLogging = false
+	rng = rand.New(rand.NewSource(1))
+	clock = time.Now
+	connTraffic.Range(func(key, _ interface{}) bool {
+		connTraffic.Delete(key)
+		return true
+	})
+	totalTraffic.sent.Store(0)
+	totalTraffic.received.Store(0)
+
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = c
+	serverDone = make(chan struct{})
+	shutdownOnce = sync.Once{}
+	metricsServer = nil
+	t.Cleanup(func() { c.Close() })
+
+	startMatch()
+}
+
+// testClient — UDP-сокет клиента, принимающий сообщения сервера
+type testClient struct {
+	t    testing.TB
+	conn *net.UDPConn
+}
+
+// newTestClient открывает сокет клиента на свободном порту
+func newTestClient(t testing.TB) *testClient {
+	t.Helper()
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return &testClient{t: t, conn: c}
+}
+
+// addr возвращает адрес клиента, с которым его видит сервер
+func (c *testClient) addr() *net.UDPAddr {
+	return c.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// send отправляет сообщение на сокет сервера
+func (c *testClient) send(msg map[string]interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.conn.WriteToUDP(data, conn.LocalAddr().(*net.UDPAddr)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next ждёт JSON-сообщение, для которого match возвращает true, пропуская
+// остальные, в том числе двоичные. Порядок UDP-пакетов не гарантирован,
+// поэтому тесты ищут нужное сообщение, а не берут следующее
+func (c *testClient) next(timeout time.Duration, match func(map[string]interface{}) bool) (map[string]interface{}, bool) {
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 64*1024)
+	for {
+		c.conn.SetReadDeadline(deadline)
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return nil, false
+		}
+		var msg map[string]interface{}
+		if json.Unmarshal(buf[:n], &msg) != nil {
+			continue
+		}
+		if match(msg) {
+			return msg, true
+		}
+	}
+}
+
+// recv ждёт сообщение с полем "type", равным msgType
+func (c *testClient) recv(msgType string) map[string]interface{} {
+	c.t.Helper()
+	msg, ok := c.next(testTimeout, func(m map[string]interface{}) bool { return m["type"] == msgType })
+	if !ok {
+		c.t.Fatalf("клиент %s не получил сообщение %q", c.addr(), msgType)
+	}
+	return msg
+}
+
+// recvError ждёт сообщение с полем "error", равным code
+func (c *testClient) recvError(code string) map[string]interface{} {
+	c.t.Helper()
+	msg, ok := c.next(testTimeout, func(m map[string]interface{}) bool { return m["error"] == code })
+	if !ok {
+		c.t.Fatalf("клиент %s не получил ошибку %q", c.addr(), code)
+	}
+	return msg
+}
+
+// expectNone проверяет, что за timeout не пришло подходящего сообщения
+func (c *testClient) expectNone(timeout time.Duration, match func(map[string]interface{}) bool) {
+	c.t.Helper()
+	if msg, ok := c.next(timeout, match); ok {
+		c.t.Fatalf("клиент %s получил лишнее сообщение %v", c.addr(), msg)
+	}
+}
+
+// isState проверяет, что сообщение — снимок состояния игры
+func isState(m map[string]interface{}) bool {
+	_, ok := m["capturePoints"]
+	return ok && m["type"] == nil
+}
+
+// recvState ждёт снимок состояния игры
+func (c *testClient) recvState() map[string]interface{} {
+	c.t.Helper()
+	msg, ok := c.next(testTimeout, isState)
+	if !ok {
+		c.t.Fatalf("клиент %s не получил состояние игры", c.addr())
+	}
+	return msg
+}
+
+// hasType возвращает условие для next по полю "type"
+func hasType(msgType string) func(map[string]interface{}) bool {
+	return func(m map[string]interface{}) bool { return m["type"] == msgType }
+}
+
+// addTestPlayer подключает игрока с отдельного клиента и ставит его в (x, y)
+// без защиты после появления
+func addTestPlayer(t testing.TB, x, y float64) (*Player, *testClient) {
+	t.Helper()
+	client := newTestClient(t)
+	addPlayer(client.addr(), joinRequest{name: "p", encoding: encodingJSON})
+	player := players[nextPlayerID]
+	player.X, player.Y = x, y
+	player.ProtectedUntil = time.Time{}
+	return player, client
+}
+
+// msgFloat возвращает числовое поле сообщения
+func msgFloat(t testing.TB, msg map[string]interface{}, key string) float64 {
+	t.Helper()
+	v, ok := msg[key].(float64)
+	if !ok {
+		t.Fatalf("в сообщении %v нет числа %q", msg, key)
+	}
+	return v
+}
+
+func TestJoinHandshake(t *testing.T) {
+	resetGame(t)
+	client := newTestClient(t)
+
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "name": "ok"})
+	joined := client.recv("joined")
+	if id := msgFloat(t, joined, "id"); id != 1 || players[1] == nil {
+		t.Fatalf("игрок не создан, ответ %v", joined)
+	}
+}
+
+func TestJoinVersionMismatch(t *testing.T) {
+	resetGame(t)
+	client := newTestClient(t)
+
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion + 1)})
+	reply := client.recvError("version_mismatch")
+	if msgFloat(t, reply, "serverVersion") != protocolVersion {
+		t.Fatalf("ответ %v", reply)
+	}
+	// Без версии клиент тоже отклоняется
+	handleJoin(client.addr(), map[string]interface{}{"type": "join"})
+	client.recvError("version_mismatch")
+	if len(players) != 0 {
+		t.Fatalf("создано игроков: %d", len(players))
+	}
+}
+
+func TestJoinResponseCarriesInitialState(t *testing.T) {
+	resetGame(t)
+	client := newTestClient(t)
+
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion)})
+	joined := client.recv("joined")
+	if msgFloat(t, joined, "id") != 1 {
+		t.Fatalf("id в ответе: %v", joined["id"])
+	}
+	spawn, _ := joined["spawn"].(map[string]interface{})
+	if msgFloat(t, spawn, "x") != 400 || msgFloat(t, spawn, "y") != 400 {
+		t.Fatalf("точка появления %v", spawn)
+	}
+	state, _ := joined["state"].(map[string]interface{})
+	points, _ := state["capturePoints"].([]interface{})
+	if len(points) != len(config.CapturePoints) {
+		t.Fatalf("точек захвата в состоянии: %d", len(points))
+	}
+	list, _ := state["players"].([]interface{})
+	if len(list) != 1 || list[0].(map[string]interface{})["id"] != 1.0 {
+		t.Fatalf("игроки в состоянии: %v", list)
+	}
+}
+
+// BenchmarkGameTick замеряет тик с рассылкой состояния в зависимости от числа
+// игроков. Все боты получают состояние на один клиентский сокет. Запуск:
+//
+//	go test -run '^$' -bench GameTick -benchmem
+//
+// Наибольшее число игроков — сколько помещается в датаграмму состояния.
+// Базовые значения (Intel Xeon, linux/amd64):
+//
+//	players=10     96000 ns/op     4280 B/op     84 allocs/op
+//	players=50    449000 ns/op    17084 B/op    404 allocs/op
+//	players=111  1201000 ns/op    36820 B/op    895 allocs/op
+func BenchmarkGameTick(b *testing.B) {
+	for _, n := range []int{10, 50, maxStatePlayers} {
+		b.Run(fmt.Sprintf("players=%d", n), func(b *testing.B) {
+			resetGame(b)
+			addBotPlayers(n)
+			sink := newTestClient(b)
+			for id := range players {
+				clientAddrs[id] = sink.addr()
+			}
+			now := time.Now()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				now = now.Add(10 * time.Millisecond)
+				gameTick(now, 10*time.Millisecond, i%100 == 0)
+			}
+			b.StopTimer()
+			if len(players) != n {
+				b.Fatalf("за время замера удалено %d игроков", n-len(players))
+			}
+		})
+	}
+}
+
+// TestParallelDispatch прогоняет поток перемещений одного игрока через
+// несколько обработчиков одновременно с игровыми тиками. Запускать с -race
+func TestParallelDispatch(t *testing.T) {
+	resetGame(t)
+	config.Workers = 4
+	player, client := addTestPlayer(t, 400, 400)
+	other, otherClient := addTestPlayer(t, 600, 400)
+	const moves = 300
+
+	packets := make(chan packet, packetQueueSize)
+	var workers sync.WaitGroup
+	for i := 0; i < config.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			packetWorker(packets)
+		}()
+	}
+
+	stopTicks := make(chan struct{})
+	ticks := make(chan struct{})
+	go func() {
+		defer close(ticks)
+		for {
+			select {
+			case <-stopTicks:
+				return
+			default:
+			}
+			mutex.Lock()
+			gameTick(time.Now(), time.Millisecond, false)
+			mutex.Unlock()
+		}
+	}()
+
+	enqueue := func(addr *net.UDPAddr, msg map[string]interface{}) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := packetPool.Get().(*[]byte)
+		n := copy(*buf, data)
+		packets <- packet{addr: addr, buf: buf, n: n}
+	}
+	for seq := 1; seq <= moves; seq++ {
+		enqueue(client.addr(), map[string]interface{}{"id": player.ID, "x": 400.0 + float64(seq)/2, "y": 400.0, "seq": seq})
+		enqueue(otherClient.addr(), map[string]interface{}{"id": other.ID, "type": "pong", "t": float64(time.Now().UnixNano())})
+	}
+	close(packets)
+	workers.Wait()
+	close(stopTicks)
+	<-ticks
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// Обработанные не по порядку старые позиции отброшены, поэтому игрок
+	// стоит на последней присланной позиции
+	if player.LastInputSeq != moves || player.X != 400+float64(moves)/2 {
+		t.Fatalf("игрок в x=%v, seq=%d, ожидалось x=%v, seq=%d", player.X, player.LastInputSeq, 400+float64(moves)/2, moves)
+	}
+	if other.Ping < 0 {
+		t.Fatal("ответы на ping второго игрока не обработаны")
+	}
+}
+
+func TestUnknownActionReplied(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+	before, targetBefore := *player, *target
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "action": "dance"})
+	reply := client.recvError("unknown_action")
+	if reply["action"] != "dance" {
+		t.Fatalf("ответ %v", reply)
+	}
+	if player.X != before.X || player.Y != before.Y || player.Points != before.Points ||
+		!player.LastActionTime.Equal(before.LastActionTime) || target.X != targetBefore.X || isKnockedBack(target) {
+		t.Fatal("неизвестное действие изменило состояние")
+	}
+}
+
+func TestFacingNormalized(t *testing.T) {
+	for _, tt := range []struct{ in, want float64 }{
+		{0, 0},
+		{math.Pi / 2, math.Pi / 2},
+		{3 * math.Pi / 2, -math.Pi / 2},
+		{-5 * math.Pi / 2, -math.Pi / 2},
+		{20*math.Pi + 0.5, 0.5},
+	} {
+		if got := normalizeAngle(tt.in); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("normalizeAngle(%v) = %v, ожидалось %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFacingSetsDirection(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	send := func(facing float64) {
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "facing": facing})
+	}
+
+	send(3 * math.Pi)
+	if math.Abs(math.Abs(player.Facing)-math.Pi) > 1e-9 || !player.FlipX {
+		t.Fatalf("взгляд влево: facing=%v, flipX=%v", player.Facing, player.FlipX)
+	}
+	send(2*math.Pi + 0.25)
+	if math.Abs(player.Facing-0.25) > 1e-9 || player.FlipX {
+		t.Fatalf("взгляд вправо: facing=%v, flipX=%v", player.Facing, player.FlipX)
+	}
+	// Некорректное направление не меняет прежнее
+	send(math.NaN())
+	if math.Abs(player.Facing-0.25) > 1e-9 {
+		t.Fatalf("NaN изменил направление: %v", player.Facing)
+	}
+}
+
+func TestDashAlongFacing(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	dash := func(facing float64) {
+		player.LastDashTime, player.LastActionTime = time.Time{}, time.Time{}
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "facing": facing, "action": "dash"})
+	}
+
+	// Направление за пределами [-π, π] приводится к нему и задаёт рывок
+	dash(5 * math.Pi / 2)
+	if math.Abs(player.X-400) > 1e-9 || math.Abs(player.Y-400-config.DashDistance) > 1e-9 {
+		t.Fatalf("рывок вниз привёл в (%v, %v)", player.X, player.Y)
+	}
+	correction := client.recv("correction")
+	if msgFloat(t, correction, "y") != player.Y {
+		t.Fatalf("коррекция %v", correction)
+	}
+	dash(-math.Pi)
+	if math.Abs(player.X-400+config.DashDistance) > 1e-9 || !player.FlipX {
+		t.Fatalf("рывок влево привёл в (%v, %v)", player.X, player.Y)
+	}
+
+	// Препятствие на пути останавливает рывок
+	config.Obstacles = []Obstacle{{X: player.X + 50, Y: player.Y - 20, Width: 10, Height: 40}}
+	x := player.X
+	dash(0)
+	if player.X > x+50 {
+		t.Fatalf("рывок прошёл сквозь препятствие: x=%v", player.X)
+	}
+}
+
+func TestDirectionalPushUsesFacing(t *testing.T) {
+	resetGame(t)
+	config.PushArc = math.Pi / 2
+	actor, _ := addTestPlayer(t, 400, 400)
+	behind, _ := addTestPlayer(t, 350, 400)
+	ahead, _ := addTestPlayer(t, 440, 430)
+
+	// Ближайший игрок позади не попадает в сектор перед толкающим
+	actor.Facing = 0
+	applyPush(actor)
+	if isKnockedBack(behind) || !isKnockedBack(ahead) {
+		t.Fatalf("толчок вперёд: позади отброшен=%v, впереди=%v", isKnockedBack(behind), isKnockedBack(ahead))
+	}
+	// Цель отбрасывается по направлению взгляда, а не от толкающего
+	if ahead.VY != 0 || ahead.VX <= 0 {
+		t.Fatalf("скорость отброса (%v, %v), ожидалась вдоль Facing", ahead.VX, ahead.VY)
+	}
+}
+
+func TestSwapWithNearestPlayer(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	near, nearClient := addTestPlayer(t, 500, 420)
+	far, _ := addTestPlayer(t, 520, 400)
+
+	applySwap(actor)
+	if actor.X != 500 || actor.Y != 420 || near.X != 400 || near.Y != 400 {
+		t.Fatalf("после обмена: игрок (%v, %v), цель (%v, %v)", actor.X, actor.Y, near.X, near.Y)
+	}
+	if far.X != 520 || far.Y != 400 {
+		t.Fatal("задет дальний игрок")
+	}
+	if swap := nearClient.recv("swap"); msgFloat(t, swap, "from") != float64(actor.ID) || msgFloat(t, swap, "to") != float64(near.ID) {
+		t.Fatalf("событие %v", swap)
+	}
+}
+
+func TestSwapWithoutTargetInRange(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 100, 100)
+	far, _ := addTestPlayer(t, 100+config.SwapRange+1, 100)
+
+	applySwap(actor)
+	if actor.X != 100 || far.X != 100+config.SwapRange+1 {
+		t.Fatalf("обмен без цели в радиусе: игрок x=%v, дальний x=%v", actor.X, far.X)
+	}
+}
+
+// checkInvariants проверяет, что состояние сервера осталось допустимым
+func checkInvariants(t *testing.T) {
+	t.Helper()
+	for id, player := range players {
+		if player.ID != id {
+			t.Fatalf("игрок %d хранится под ID %d", player.ID, id)
+		}
+		if !isFinite(player.X) || !isFinite(player.Y) || !insideWorld(config, player.X, player.Y) {
+			t.Fatalf("игрок %d в недопустимой позиции (%v, %v)", id, player.X, player.Y)
+		}
+		if !isFinite(player.Facing) || math.Abs(player.Facing) > math.Pi {
+			t.Fatalf("игрок %d смотрит в недопустимом направлении %v", id, player.Facing)
+		}
+		if !isFinite(player.VX) || !isFinite(player.VY) {
+			t.Fatalf("игрок %d с недопустимой скоростью (%v, %v)", id, player.VX, player.VY)
+		}
+		addr, ok := clientAddrs[id]
+		if !ok || !ownsPlayer(addr, id) {
+			t.Fatalf("у игрока %d нет согласованного адреса", id)
+		}
+	}
+	for key, ids := range addrPlayers {
+		for _, id := range ids {
+			if addr, ok := clientAddrs[id]; !ok || addr.String() != key {
+				t.Fatalf("адрес %s ссылается на чужого или удалённого игрока %d", key, id)
+			}
+		}
+	}
+	if len(clientAddrs) != len(players) {
+		t.Fatalf("адресов %d, игроков %d", len(clientAddrs), len(players))
+	}
+	if config.MaxPlayers > 0 && len(players) > config.MaxPlayers {
+		t.Fatalf("игроков %d при лимите %d", len(players), config.MaxPlayers)
+	}
+	if len(capturePoints) != len(config.CapturePoints) {
+		t.Fatalf("точек захвата %d", len(capturePoints))
+	}
+}
+
+// FuzzHandleMessage подаёт произвольные байты во входную точку handlePacket.
+// Сервер не должен паниковать и не должен приходить в недопустимое состояние.
+// Запуск: go test -run '^$' -fuzz FuzzHandleMessage
+func FuzzHandleMessage(f *testing.F) {
+	for _, seed := range []string{
+		`{"type":"join","protocolVersion":1}`,
+		`{"type":"join","protocolVersion":1,"name":7,"skin":null}`,
+		`{"type":"join","protocolVersion":"1"}`,
+		`{"type":"join","protocolVersion":1,"spectator":true}`,
+		`{"id":1,"x":1e308,"y":-1e308}`,
+		`{"id":1,"x":1e999}`,
+		`{"id":1,"x":"NaN","y":null}`,
+		`{"id":2,"x":10,"y":10}`,
+		`{"id":"1","action":"push"}`,
+		`{"id":1e20,"action":"push"}`,
+		`{"id":-1}`,
+		`{"id":1,"action":7}`,
+		`{"id":1,"action":"push","seq":1e300}`,
+		`{"id":1,"action":"emote","emote":{"a":1}}`,
+		`{"id":1,"action":"freeze"}`,
+		`{"id":1,"facing":1e308,"flipX":"yes"}`,
+		`{"id":1,"type":"pong","t":-1e300}`,
+		`{"id":1,"type":"ack","rseq":"x"}`,
+		`{"id":1,"type":"leave"}`,
+		`{"type":"admin","command":"pause"}`,
+		`{"type":"admin","token":"","command":"team","id":1,"team":99}`,
+		`null`,
+		`[]`,
+		`"join"`,
+		`{`,
+		``,
+		"\x02\x01\x00\x00\x00\x00\x00\xc0\x7f\x00\x00\xc0\x7f\x01\x00\x00\x00\x01",
+		"\x02\x01\x00\x00\x00\x00\x00\x80\x7f\x00\x00\x80\xff\xff\xff\xff\xff\x00",
+		"\x02\x01\x00",
+		"\x01\x00\x00",
+	} {
+		f.Add([]byte(seed), false)
+		f.Add([]byte(seed), true)
+	}
+
+	resetGame(f)
+	config.MaxPlayers = 8
+	config.WaitingRoom = true
+	clients := []*testClient{newTestClient(f), newTestClient(f)}
+	for _, c := range clients {
+		addPlayer(c.addr(), joinRequest{name: "fuzz", encoding: encodingJSON})
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, second bool) {
+		addr := clients[0].addr()
+		if second {
+			addr = clients[1].addr()
+		}
+		handlePacket(addr, data, false)
+		gameTick(time.Now(), 10*time.Millisecond, false)
+		updateCapturePoints()
+		checkInvariants(t)
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// MapPreset — готовая карта: препятствия, точки появления и точки захвата
+type MapPreset struct {
+	Obstacles     []Obstacle
+	SpawnPoints   []Point
+	CapturePoints []CapturePointConfig
+}
+
+// mapPresets — встроенные карты, выбираемые настройкой Map или флагом -map
+var mapPresets = map[string]MapPreset{
+	// Классическая карта с тремя точками, совпадает с картой по умолчанию
+	"classic-3": {
+		SpawnPoints: []Point{{X: 400, Y: 400}},
+		CapturePoints: []CapturePointConfig{
+			{X: 300, Y: 200, Radius: 50},
+			{X: 800, Y: 600, Radius: 50},
+			{X: 550, Y: 400, Radius: 50},
+		},
+	},
+	// Пять точек: четыре по углам и ценная центральная за укрытиями
+	"five-point": {
+		Obstacles: []Obstacle{
+			{X: 480, Y: 280, Width: 140, Height: 20},
+			{X: 480, Y: 500, Width: 140, Height: 20},
+		},
+		SpawnPoints: []Point{{X: 100, Y: 400}, {X: 1000, Y: 400}},
+		CapturePoints: []CapturePointConfig{
+			{X: 200, Y: 150, Radius: 50},
+			{X: 900, Y: 150, Radius: 50},
+			{X: 200, Y: 650, Radius: 50},
+			{X: 900, Y: 650, Radius: 50},
+			{X: 550, Y: 400, Radius: 60, ScoreWeight: 2},
+		},
+	},
+	// Дуэль: одна точка посередине между двумя точками появления
+	"duel": {
+		Obstacles: []Obstacle{
+			{X: 390, Y: 250, Width: 20, Height: 80},
+			{X: 390, Y: 470, Width: 20, Height: 80},
+		},
+		SpawnPoints: []Point{{X: 150, Y: 400}, {X: 650, Y: 400}},
+		CapturePoints: []CapturePointConfig{
+			{X: 400, Y: 400, Radius: 60},
+		},
+	},
+}
+
+// validateMap проверяет, что на карте можно играть
+func validateMap(m MapPreset) error {
+	if len(m.SpawnPoints) == 0 {
+		return fmt.Errorf("на карте нет точек появления")
+	}
+	if len(m.CapturePoints) == 0 {
+		return fmt.Errorf("на карте нет точек захвата")
+	}
+	for i, o := range m.Obstacles {
+		if o.Width <= 0 || o.Height <= 0 {
+			return fmt.Errorf("препятствие %d: размеры должны быть положительными", i+1)
+		}
+	}
+	if err := validateCapturePoints(m.CapturePoints); err != nil {
+		return err
+	}
+	// Встроенные карты нарисованы для мира referenceWorldWidth ×
+	// referenceWorldHeight и проверяются так же, как точки из конфигурации
+	reference := Config{
+		WorldWidth:    referenceWorldWidth,
+		WorldHeight:   referenceWorldHeight,
+		SpawnPoints:   m.SpawnPoints,
+		CapturePoints: m.CapturePoints,
+	}
+	if err := validateWorld(reference); err != nil {
+		return err
+	}
+	return validateGeometry(m.Obstacles, m.SpawnPoints, m.CapturePoints)
+}
+
+// validateGeometry проверяет взаимное расположение объектов карты: зона
+// захвата, задетая препятствием, может оказаться недостижимой, а игрок,
+// появившийся в препятствии или в зоне, — застрять или сразу начать захват
+func validateGeometry(obstacles []Obstacle, spawns []Point, points []CapturePointConfig) error {
+	for i, cp := range points {
+		for j, o := range obstacles {
+			if o.distanceTo(cp.X, cp.Y) < cp.Radius {
+				return fmt.Errorf("точка захвата %d (%.0f, %.0f) пересекается с препятствием %d", i+1, cp.X, cp.Y, j+1)
+			}
+		}
+	}
+	for i, p := range spawns {
+		for j, o := range obstacles {
+			if o.contains(p.X, p.Y) {
+				return fmt.Errorf("точка появления %d (%.0f, %.0f) внутри препятствия %d", i+1, p.X, p.Y, j+1)
+			}
+		}
+		for j, cp := range points {
+			if math.Hypot(p.X-cp.X, p.Y-cp.Y) <= cp.Radius {
+				return fmt.Errorf("точка появления %d (%.0f, %.0f) в зоне точки захвата %d", i+1, p.X, p.Y, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCapturePoints проверяет параметры точек захвата. Точку с
+// неположительным радиусом невозможно захватить
+func validateCapturePoints(points []CapturePointConfig) error {
+	for i, cp := range points {
+		if cp.Radius <= 0 {
+			return fmt.Errorf("точка захвата %d: радиус должен быть положительным", i+1)
+		}
+		if cp.ScoreWeight < 0 {
+			return fmt.Errorf("точка захвата %d: вес очков не может быть отрицательным", i+1)
+		}
+	}
+	return nil
+}
+
+// applyMapPreset подставляет в cfg геометрию карты с именем name
+func applyMapPreset(cfg *Config, name string) error {
+	preset, ok := mapPresets[name]
+	if !ok {
+		return fmt.Errorf("неизвестная карта %q", name)
+	}
+	if err := validateMap(preset); err != nil {
+		return fmt.Errorf("карта %q: %w", name, err)
+	}
+	cfg.Map = name
+	cfg.Obstacles = preset.Obstacles
+	cfg.SpawnPoints = preset.SpawnPoints
+	cfg.CapturePoints = preset.CapturePoints
+	return nil
+}
+
+// Доля радиуса меньшей зоны, при перекрытии на которую выводится предупреждение
+const heavyOverlap = 0.5
+
+// warnOverlappingPoints предупреждает о сильно перекрывающихся зонах захвата:
+// такая карта работает, но, скорее всего, нарисована с ошибкой
+func warnOverlappingPoints(points []CapturePointConfig) {
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			a, b := points[i], points[j]
+			overlap := a.Radius + b.Radius - math.Hypot(a.X-b.X, a.Y-b.Y)
+			if overlap > heavyOverlap*min(a.Radius, b.Radius) {
+				log.Printf("Предупреждение: точки захвата %d и %d сильно перекрываются", i+1, j+1)
+			}
+		}
+	}
+}
+
+// mapPresetNames перечисляет встроенные карты через запятую
+func mapPresetNames() string {
+	names := make([]string, 0, len(mapPresets))
+	for name := range mapPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMapFlagAppliesPreset(t *testing.T) {
+	resetGame(t)
+	mapFlag = "five-point"
+	preset := mapPresets[mapFlag]
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Map != mapFlag || len(cfg.CapturePoints) != len(preset.CapturePoints) || len(cfg.SpawnPoints) != len(preset.SpawnPoints) {
+		t.Fatalf("карта %q: %d точек захвата, %d точек появления", cfg.Map, len(cfg.CapturePoints), len(cfg.SpawnPoints))
+	}
+
+	config = cfg
+	startMatch()
+	if len(capturePoints) != len(preset.CapturePoints) || capturePoints[4].ScoreWeight != 2 {
+		t.Fatalf("точки матча не взяты с карты: %+v", capturePoints)
+	}
+}
+
+func TestMapFlagOverridesFileAndScales(t *testing.T) {
+	resetGame(t)
+	mapFlag = "duel"
+	preset := mapPresets[mapFlag]
+
+	// Флаг заменяет карту из файла, а карта проходит масштабирование мира
+	cfg, err := loadConfig(writeConfig(t, `{"map": "five-point", "worldWidth": 2000, "worldHeight": 1600, "scaleMapToWorld": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Map != mapFlag || len(cfg.CapturePoints) != len(preset.CapturePoints) {
+		t.Fatalf("карта %q с %d точками захвата", cfg.Map, len(cfg.CapturePoints))
+	}
+	if cfg.SpawnPoints[1].X != 2*preset.SpawnPoints[1].X || cfg.CapturePoints[0].Radius != 2*preset.CapturePoints[0].Radius {
+		t.Fatalf("карта не масштабирована: %+v, %+v", cfg.SpawnPoints, cfg.CapturePoints)
+	}
+
+	// Ограничение числа точек действует и для карты из флага
+	mapFlag = "five-point"
+	if _, err := loadConfig(writeConfig(t, `{"maxCapturePoints": 3}`)); err == nil {
+		t.Fatal("карта с пятью точками принята при ограничении в три")
+	}
+}
+
+func TestPresetValidatedAgainstWorld(t *testing.T) {
+	resetGame(t)
+	mapPresets["off-map"] = MapPreset{
+		SpawnPoints:   []Point{{X: 100, Y: 100}},
+		CapturePoints: []CapturePointConfig{{X: 1200, Y: 400, Radius: 50}},
+	}
+	t.Cleanup(func() { delete(mapPresets, "off-map") })
+
+	var cfg Config
+	err := applyMapPreset(&cfg, "off-map")
+	if err == nil || !strings.Contains(err.Error(), "точка захвата 1 (1200, 400) за пределами мира") {
+		t.Fatalf("карта с точкой за пределами мира: %v", err)
+	}
+
+	// Встроенная карта в мире меньше того, для которого она нарисована,
+	// без масштабирования не загружается
+	if _, err := loadConfig(writeConfig(t, `{"map": "five-point", "worldWidth": 600, "worldHeight": 500}`)); err == nil {
+		t.Fatal("карта загружена в мир, за пределы которого выходят её точки")
+	}
+}
+
+func TestGeometryValidation(t *testing.T) {
+	resetGame(t)
+	for _, tt := range []struct {
+		config string
+		want   string
+	}{
+		{`{"obstacles": [{"x": 280, "y": 180, "width": 40, "height": 40}]}`, "точка захвата 1 (300, 200) пересекается с препятствием 1"},
+		{`{"obstacles": [{"x": 380, "y": 380, "width": 40, "height": 40}]}`, "точка появления 1 (400, 400) внутри препятствия 1"},
+		{`{"spawnPoints": [{"x": 810, "y": 590}]}`, "точка появления 1 (810, 590) в зоне точки захвата 2"},
+	} {
+		_, err := loadConfig(writeConfig(t, tt.config))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ошибка %v, ожидалась %q", tt.config, err, tt.want)
+		}
+	}
+
+	// Препятствие в стороне от точек не мешает
+	if _, err := loadConfig(writeConfig(t, `{"obstacles": [{"x": 600, "y": 100, "width": 40, "height": 40}]}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapQueryReturnsGeometry(t *testing.T) {
+	resetGame(t)
+	config.Obstacles = []Obstacle{{X: 600, Y: 100, Width: 40, Height: 30}}
+	player, client := addTestPlayer(t, 400, 400)
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "action": "map"})
+	reply, _ := client.recv("map")["map"].(map[string]interface{})
+	if msgFloat(t, reply, "width") != config.WorldWidth || msgFloat(t, reply, "height") != config.WorldHeight {
+		t.Fatalf("границы карты: %v", reply)
+	}
+	obstacles, _ := reply["obstacles"].([]interface{})
+	if len(obstacles) != 1 {
+		t.Fatalf("препятствия: %v", reply["obstacles"])
+	}
+	if o := obstacles[0].(map[string]interface{}); msgFloat(t, o, "x") != 600 || msgFloat(t, o, "width") != 40 || msgFloat(t, o, "height") != 30 {
+		t.Fatalf("препятствие %v", o)
+	}
+	points, _ := reply["capturePoints"].([]interface{})
+	if len(points) != len(config.CapturePoints) {
+		t.Fatalf("точек захвата %d, ожидалось %d", len(points), len(config.CapturePoints))
+	}
+	for i, p := range points {
+		p := p.(map[string]interface{})
+		want := config.CapturePoints[i]
+		if msgFloat(t, p, "x") != want.X || msgFloat(t, p, "y") != want.Y || msgFloat(t, p, "radius") != want.Radius {
+			t.Fatalf("точка %d: %v, ожидалась %+v", i+1, p, want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Фазы матча
+const (
+	phasePlaying  = "playing"  // Основное время
+	phaseOvertime = "overtime" // Овертайм: побеждает первый, кто вырвется вперёд
+	phaseEnded    = "ended"    // Матч завершён
+	phaseWaiting  = "waiting"  // Матч приостановлен: игроков меньше MinPlayers
+	phasePaused   = "paused"   // Матч остановлен администратором
+)
+
+var (
+	matchPhase = phasePlaying
+	matchEnd   time.Time // Окончание основного времени (нулевое — матч без таймера)
+
+	pausedPhase   string    // Фаза, к которой матч вернётся после ожидания игроков или паузы
+	pausedAt      time.Time // Начало ожидания игроков или паузы
+	adminPausedAt time.Time // Начало паузы администратора
+	matchEndedAt  time.Time // Когда завершился последний матч
+)
+
+// startMatch начинает новый матч, вызывается под mutex
+func startMatch() {
+	applyPendingMap()
+	loadCapturePoints()
+	resetStats()
+	matchPhase = phasePlaying
+	matchEnd = time.Time{}
+	if duration := time.Duration(config.MatchDuration); duration > 0 {
+		matchEnd = clock().Add(duration)
+	}
+}
+
+// restartMatch начинает новый матч с теми же игроками: очки сбрасываются,
+// точки становятся нейтральными, а игроки возвращаются на точки появления.
+// Вызывается под mutex
+func restartMatch() {
+	startMatch()
+	for _, player := range players {
+		player.Points = config.StartingPoints
+		broadcastReliable(map[string]interface{}{
+			"type":   "score",
+			"id":     player.ID,
+			"points": player.Points,
+		}, 0)
+		player.Buffs = nil
+		player.FrozenUntil = time.Time{}
+		respawnPlayer(player)
+	}
+	log.Printf("Начат новый матч")
+	broadcastPhase()
+}
+
+// matchActive проверяет, идёт ли сейчас захват точек и начисление очков,
+// вызывается под mutex
+func matchActive() bool {
+	return matchPhase == phasePlaying || matchPhase == phaseOvertime
+}
+
+// updateMinPlayers приостанавливает матч, когда игроков меньше MinPlayers,
+// и продолжает его, когда их снова достаточно. Вызывается под mutex
+func updateMinPlayers(now time.Time) {
+	enough := len(players) >= config.MinPlayers
+	switch {
+	case matchActive() && !enough:
+		suspendMatch(phaseWaiting, now)
+		log.Printf("Игроков меньше %d, матч приостановлен", config.MinPlayers)
+	case matchPhase == phaseWaiting && enough:
+		resumeMatch(now)
+		log.Printf("Игроков достаточно, матч продолжается")
+	default:
+		return
+	}
+	broadcastPhase()
+}
+
+// suspendMatch останавливает идущий матч, переводя его в фазу phase,
+// вызывается под mutex
+func suspendMatch(phase string, now time.Time) {
+	pausedPhase = matchPhase
+	pausedAt = now
+	matchPhase = phase
+}
+
+// resumeMatch возвращает матч в фазу, из которой он был остановлен. Таймеры
+// сдвигаются на время остановки, чтобы она не засчитывалась ни в
+// продолжительность матча, ни в захват и удержание точек, ни в бездействие
+// игроков. Вызывается под mutex
+func resumeMatch(now time.Time) {
+	paused := now.Sub(pausedAt)
+	if !matchEnd.IsZero() {
+		matchEnd = matchEnd.Add(paused)
+	}
+	if !nextRotation.IsZero() {
+		nextRotation = nextRotation.Add(paused)
+	}
+	if !lastTimeOnPointUpdate.IsZero() {
+		lastTimeOnPointUpdate = lastTimeOnPointUpdate.Add(paused)
+	}
+	for i := range capturePoints {
+		cp := &capturePoints[i]
+		for _, t := range []*time.Time{&cp.EnterTime, &cp.CaptureStart, &cp.HoldStart, &cp.PausedAt, &cp.DrainStart} {
+			if !t.IsZero() {
+				*t = t.Add(paused)
+			}
+		}
+	}
+	// Игрок, действовавший во время остановки, бездействует с её конца
+	for _, player := range players {
+		if player.LastActivity.Before(pausedAt) {
+			player.LastActivity = player.LastActivity.Add(paused)
+		} else {
+			player.LastActivity = now
+		}
+	}
+	matchPhase = pausedPhase
+}
+
+// pauseMatch останавливает матч по команде администратора: точки не
+// захватываются, очки не начисляются, отброс замирает, а движение и атаки
+// игроков игнорируются. Вызывается под mutex
+func pauseMatch(now time.Time) bool {
+	switch {
+	case matchActive():
+		suspendMatch(phasePaused, now)
+	case matchPhase == phaseWaiting:
+		// Матч уже стоит в ожидании игроков: отсчёт паузы идёт с начала ожидания
+		matchPhase = phasePaused
+	default:
+		return false
+	}
+	adminPausedAt = now
+	log.Printf("Матч поставлен на паузу")
+	broadcastPhase()
+	return true
+}
+
+// unpauseMatch снимает паузу администратора. Если игроков меньше MinPlayers,
+// матч переходит в ожидание. Вызывается под mutex
+func unpauseMatch(now time.Time) bool {
+	if matchPhase != phasePaused {
+		return false
+	}
+	shiftPlayerTimers(now.Sub(adminPausedAt))
+	if len(players) < config.MinPlayers {
+		matchPhase = phaseWaiting
+	} else {
+		resumeMatch(now)
+	}
+	log.Printf("Пауза снята, фаза матча: %s", matchPhase)
+	broadcastPhase()
+	return true
+}
+
+// shiftPlayerTimers продлевает эффекты игроков на длительность паузы
+// администратора: отброс, заморозка, защита после появления и усиления
+// продолжаются с того места, где их застала пауза. Время бездействия
+// сдвигает resumeMatch, общий для паузы и ожидания игроков. Вызывается под mutex
+func shiftPlayerTimers(paused time.Duration) {
+	shift := func(t *time.Time) {
+		if t.After(adminPausedAt) {
+			*t = t.Add(paused)
+		}
+	}
+	for _, player := range players {
+		if isKnockedBack(player) {
+			player.KnockbackUntil = player.KnockbackUntil.Add(paused)
+		}
+		shift(&player.FrozenUntil)
+		shift(&player.ProtectedUntil)
+		for i := range player.Buffs {
+			shift(&player.Buffs[i].ExpiresAt)
+		}
+	}
+}
+
+// broadcastPhase сообщает клиентам о смене фазы матча
+func broadcastPhase() {
+	broadcastEvent(map[string]interface{}{
+		"type":  "phase",
+		"phase": matchPhase,
+	})
+}
+
+// updateMatch проверяет истечение времени матча, вызывается под mutex
+func updateMatch(now time.Time) {
+	if matchPhase == phaseEnded {
+		// Итоги показываются PostMatchHold, затем начинается новый матч
+		if hold := time.Duration(config.PostMatchHold); hold > 0 && now.Sub(matchEndedAt) >= hold {
+			restartMatch()
+		}
+		return
+	}
+	updateMinPlayers(now)
+	if matchPhase != phasePlaying || matchEnd.IsZero() || now.Before(matchEnd) {
+		return
+	}
+
+	leaders := matchLeaders()
+	if len(leaders) > 1 && config.SuddenDeath {
+		matchPhase = phaseOvertime
+		log.Printf("Ничья по окончании времени, овертайм")
+		broadcastEvent(map[string]interface{}{
+			"type":  "phase",
+			"phase": phaseOvertime,
+		})
+		return
+	}
+
+	// Без овертайма ничья разрешается в пользу игрока с меньшим ID
+	var winner *Player
+	if len(leaders) > 0 {
+		winner = leaders[0]
+	}
+	endMatch(winner)
+}
+
+// awardPoints начисляет игроку очки, вызывается под mutex
+func awardPoints(player *Player, points int) {
+	if points == 0 {
+		return
+	}
+	player.Points += points
+	// Таблица очков меняется редко, поэтому клиенты получают только изменения
+	broadcastReliable(map[string]interface{}{
+		"type":   "score",
+		"id":     player.ID,
+		"points": player.Points,
+	}, 0)
+
+	if matchPhase == phasePlaying && config.ScoreToWin > 0 && player.Points >= config.ScoreToWin {
+		endMatch(player)
+		return
+	}
+
+	// В овертайме матч заканчивается, как только появляется единственный лидер
+	if matchPhase == phaseOvertime {
+		if leaders := matchLeaders(); len(leaders) == 1 {
+			endMatch(leaders[0])
+		}
+	}
+}
+
+// endMatch завершает матч и рассылает итоговую таблицу, вызывается под mutex
+func endMatch(winner *Player) {
+	matchPhase = phaseEnded
+	matchEndedAt = clock()
+
+	winnerID := 0
+	if winner != nil {
+		winnerID = winner.ID
+	}
+	log.Printf("Матч завершён, победитель: игрок %d", winnerID)
+	recordMatch(winner)
+	audit("matchEnd", map[string]interface{}{
+		"winner":    auditPlayer(winner),
+		"standings": standings(),
+	})
+
+	// Итоги матча приходят один раз, поэтому доставляются с подтверждением
+	broadcastReliable(map[string]interface{}{
+		"type":      "matchEnd",
+		"winner":    winnerID,
+		"standings": standings(),
+		"summary":   matchSummary(),
+	}, 0)
+}
+
+// matchLeaders возвращает игроков с наибольшим количеством очков по
+// возрастанию ID, вызывается под mutex
+func matchLeaders() []*Player {
+	var leaders []*Player
+	for _, player := range players {
+		if len(leaders) == 0 || player.Points > leaders[0].Points {
+			leaders = []*Player{player}
+		} else if player.Points == leaders[0].Points {
+			leaders = append(leaders, player)
+		}
+	}
+	sort.Slice(leaders, func(i, j int) bool { return leaders[i].ID < leaders[j].ID })
+	return leaders
+}
+
+// standings возвращает таблицу игроков по убыванию очков, вызывается под mutex
+func standings() []map[string]interface{} {
+	sorted := make([]*Player, 0, len(players))
+	for _, player := range players {
+		sorted = append(sorted, player)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Points != sorted[j].Points {
+			return sorted[i].Points > sorted[j].Points
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	result := make([]map[string]interface{}, 0, len(sorted))
+	for _, player := range sorted {
+		result = append(result, map[string]interface{}{
+			"id":     player.ID,
+			"name":   player.Name,
+			"points": player.Points,
+		})
+	}
+	return result
+}
+
+// playersList возвращает краткий список игроков в порядке таблицы для
+// запроса "players", вызывается под mutex
+func playersList() []map[string]interface{} {
+	list := standings()
+	for _, entry := range list {
+		player := players[entry["id"].(int)]
+		entry["skin"] = player.Skin
+		entry["team"] = player.Team
+	}
+	return list
+}
+
+// matchStatus описывает текущее состояние матча для клиентов, вызывается под mutex
+func matchStatus() map[string]interface{} {
+	// Оставшееся время в секундах, 0 — если таймер не задан или истёк
+	remaining := 0.0
+	if !matchEnd.IsZero() {
+		switch {
+		case matchPhase == phasePlaying:
+			remaining = max(matchEnd.Sub(clock()).Seconds(), 0)
+		case (matchPhase == phaseWaiting || matchPhase == phasePaused) && pausedPhase == phasePlaying:
+			// Во время ожидания и паузы таймер стоит
+			remaining = max(matchEnd.Sub(pausedAt).Seconds(), 0)
+		}
+	}
+	return map[string]interface{}{
+		"type":          "status",
+		"phase":         matchPhase,
+		"timeRemaining": remaining,
+		"scoreToWin":    config.ScoreToWin,
+		"standings":     standings(),
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieEntersOvertimeAndEndsOnNextScore(t *testing.T) {
+	resetGame(t)
+	leader, client := addTestPlayer(t, 100, 700)
+	addTestPlayer(t, 900, 100)
+	matchEnd = time.Now().Add(-time.Second)
+
+	updateMatch(time.Now())
+	if matchPhase != phaseOvertime {
+		t.Fatalf("фаза после ничьей: %s", matchPhase)
+	}
+
+	// Первые же очки за точку выводят игрока вперёд и завершают матч
+	ownPoint(&capturePoints[0], leader, scoreInterval)
+	updateCapturePoints()
+	if matchPhase != phaseEnded {
+		t.Fatalf("фаза после очков в овертайме: %s", matchPhase)
+	}
+	end := client.recv("matchEnd")
+	if msgFloat(t, end, "winner") != float64(leader.ID) {
+		t.Fatalf("победитель %v", end["winner"])
+	}
+}
+
+func TestMatchEndRetransmittedUntilAcked(t *testing.T) {
+	resetGame(t)
+	leader, client := addTestPlayer(t, 100, 700)
+	leader.Points = 3
+	matchEnd = time.Now().Add(-time.Second)
+
+	updateMatch(time.Now())
+	first := client.recv("matchEnd")
+	if _, ok := first["rseq"]; !ok {
+		t.Fatal("итоги матча отправлены без подтверждения")
+	}
+
+	// Потерянные итоги приходят повторно, пока клиент их не подтвердит
+	retransmitReliable(time.Now().Add(reliableRetryInterval))
+	if again := client.recv("matchEnd"); again["rseq"] != first["rseq"] {
+		t.Fatalf("повтор с другим номером: %v и %v", first["rseq"], again["rseq"])
+	}
+	handleAck(leader, map[string]interface{}{"rseq": first["rseq"]})
+	retransmitReliable(time.Now().Add(time.Hour))
+	client.expectNone(100*time.Millisecond, func(msg map[string]interface{}) bool { return msg["type"] == "matchEnd" })
+}
+
+func TestNoOvertimeWithoutTie(t *testing.T) {
+	resetGame(t)
+	leader, _ := addTestPlayer(t, 100, 700)
+	addTestPlayer(t, 900, 100)
+	leader.Points = 3
+	matchEnd = time.Now().Add(-time.Second)
+
+	updateMatch(time.Now())
+	if matchPhase != phaseEnded {
+		t.Fatalf("фаза: %s", matchPhase)
+	}
+}
+
+func TestJoinCarriesMatchStatus(t *testing.T) {
+	resetGame(t)
+	config.MatchDuration = Duration(5 * time.Minute)
+	config.ScoreToWin = 50
+	startMatch()
+	client := newTestClient(t)
+
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion)})
+	status, _ := client.recv("joined")["status"].(map[string]interface{})
+	if status["phase"] != phasePlaying {
+		t.Fatalf("фаза %v, ожидалась %q", status["phase"], phasePlaying)
+	}
+	if remaining := msgFloat(t, status, "timeRemaining"); remaining <= 290 || remaining > 300 {
+		t.Fatalf("осталось %v с, ожидалось около 300", remaining)
+	}
+	if msgFloat(t, status, "scoreToWin") != 50 {
+		t.Fatalf("порог победы %v", status["scoreToWin"])
+	}
+	if standings, _ := status["standings"].([]interface{}); len(standings) != 1 {
+		t.Fatalf("таблица %v", status["standings"])
+	}
+}
+
+// checkPointsOnce выполняет одну проверку точек, как checkCapturePoints
+func checkPointsOnce() {
+	updateMatch(time.Now())
+	if matchActive() {
+		updateCapturePoints()
+	}
+}
+
+func TestMinPlayersPausesScoring(t *testing.T) {
+	resetGame(t)
+	config.MinPlayers = 2
+	holder, _ := addTestPlayer(t, 100, 700)
+	other, _ := addTestPlayer(t, 900, 100)
+	cp := &capturePoints[0]
+	ownPoint(cp, holder, scoreInterval)
+
+	removePlayer(other.ID, "left")
+	checkPointsOnce()
+	if matchPhase != phaseWaiting || holder.Points != 0 {
+		t.Fatalf("фаза %q, очки %d: матч не приостановлен", matchPhase, holder.Points)
+	}
+	// Пока матч стоит, точки не захватываются
+	holder.X, holder.Y = capturePoints[1].X, capturePoints[1].Y
+	capturePoints[1].CurrentCapturingPlayer = holder.ID
+	capturePoints[1].EnterTime = time.Now().Add(-captureTime)
+	checkPointsOnce()
+	if holder.Points != 0 || capturePoints[1].IsCaptured {
+		t.Fatalf("в ожидании игроков начислено %d очков", holder.Points)
+	}
+
+	addTestPlayer(t, 900, 100)
+	checkPointsOnce()
+	if matchPhase != phasePlaying {
+		t.Fatalf("фаза %q после возвращения игроков", matchPhase)
+	}
+	cp.CaptureStart = time.Now().Add(-scoreInterval)
+	checkPointsOnce()
+	if holder.Points == 0 {
+		t.Fatal("после возобновления очки не начисляются")
+	}
+}
+
+func TestPlayersQuerySortedByPoints(t *testing.T) {
+	resetGame(t)
+	asker, client := addTestPlayer(t, 100, 100)
+	second, _ := addTestPlayer(t, 200, 100)
+	third, _ := addTestPlayer(t, 300, 100)
+	asker.Points, second.Points, third.Points = 3, 9, 3
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(asker.ID), "action": "players"})
+	list, _ := client.recv("players")["players"].([]interface{})
+	// При равных очках порядок по ID
+	want := []*Player{second, asker, third}
+	if len(list) != len(want) {
+		t.Fatalf("в списке %d игроков", len(list))
+	}
+	for i, player := range want {
+		entry := list[i].(map[string]interface{})
+		if msgFloat(t, entry, "id") != float64(player.ID) || msgFloat(t, entry, "points") != float64(player.Points) {
+			t.Fatalf("место %d: %v, ожидался игрок %d", i+1, entry, player.ID)
+		}
+		for _, key := range []string{"name", "skin", "team"} {
+			if _, ok := entry[key]; !ok {
+				t.Fatalf("в записи нет %q: %v", key, entry)
+			}
+		}
+	}
+}
+
+func TestAdminPauseFreezesProgress(t *testing.T) {
+	resetGame(t)
+	cp, owned := &capturePoints[0], &capturePoints[1]
+	capturer, _ := addTestPlayer(t, cp.X, cp.Y)
+	holder, _ := addTestPlayer(t, 100, 700)
+
+	// Пауза началась 5 с назад, когда захват и интервал очков были пройдены
+	// наполовину, а эффектам владельца точки оставалась секунда
+	pauseStart := time.Now().Add(-5 * time.Second)
+	cp.CurrentCapturingPlayer = capturer.ID
+	cp.EnterTime = pauseStart.Add(-captureTime / 2)
+	ownPoint(owned, holder, 0)
+	owned.CaptureStart = pauseStart.Add(-scoreInterval / 2)
+	holder.FrozenUntil = pauseStart.Add(time.Second)
+	holder.ProtectedUntil = pauseStart.Add(time.Second)
+	holder.Buffs = []Buff{{Type: buffSpeed, Magnitude: 1, ExpiresAt: pauseStart.Add(time.Second)}}
+	if !pauseMatch(pauseStart) {
+		t.Fatal("матч не поставлен на паузу")
+	}
+
+	// На паузе ничего не захватывается, очки не начисляются, усиления не истекают
+	checkPointsOnce()
+	gameTick(time.Now(), 10*time.Millisecond, false)
+	if cp.IsCaptured || holder.Points != 0 || len(holder.Buffs) != 1 {
+		t.Fatalf("на паузе: захвачена=%v, очков %d, усилений %d", cp.IsCaptured, holder.Points, len(holder.Buffs))
+	}
+
+	// После паузы прогресс и эффекты продолжаются с того же места
+	now := time.Now()
+	if !unpauseMatch(now) {
+		t.Fatal("пауза не снята")
+	}
+	near := func(got, want time.Time) bool { return got.Sub(want).Abs() < 100*time.Millisecond }
+	if !near(cp.EnterTime, now.Add(-captureTime/2)) || !near(owned.CaptureStart, now.Add(-scoreInterval/2)) {
+		t.Fatalf("прогресс после паузы: захват с %v, очки с %v", now.Sub(cp.EnterTime), now.Sub(owned.CaptureStart))
+	}
+	for what, until := range map[string]time.Time{
+		"заморозка": holder.FrozenUntil,
+		"защита":    holder.ProtectedUntil,
+		"усиление":  holder.Buffs[0].ExpiresAt,
+	} {
+		if !near(until, now.Add(time.Second)) {
+			t.Fatalf("%s после паузы продлится %v, ожидалась секунда", what, until.Sub(now))
+		}
+	}
+	checkPointsOnce()
+	if cp.IsCaptured || holder.Points != 0 {
+		t.Fatalf("сразу после паузы: захвачена=%v, очков %d", cp.IsCaptured, holder.Points)
+	}
+
+	// Оставшаяся половина проходит как обычно
+	cp.EnterTime = cp.EnterTime.Add(-captureTime / 2)
+	owned.CaptureStart = owned.CaptureStart.Add(-scoreInterval / 2)
+	checkPointsOnce()
+	if !cp.IsCaptured || holder.Points != 1 {
+		t.Fatalf("после паузы: захвачена=%v, очков %d", cp.IsCaptured, holder.Points)
+	}
+}
+
+func TestMatchRestartsAfterHold(t *testing.T) {
+	resetGame(t)
+	winner, client := addTestPlayer(t, 111, 222)
+	winner.Points = 7
+	ownPoint(&capturePoints[0], winner, scoreInterval)
+	matchEnd = time.Now().Add(-time.Millisecond)
+
+	updateMatch(time.Now())
+	if matchPhase != phaseEnded {
+		t.Fatalf("фаза после конца времени: %s", matchPhase)
+	}
+	// Пока идёт показ итогов, матч стоит
+	updateMatch(time.Now())
+	if matchPhase != phaseEnded || winner.Points != 7 {
+		t.Fatalf("до конца показа итогов: фаза %s, очков %d", matchPhase, winner.Points)
+	}
+
+	matchEndedAt = time.Now().Add(-time.Duration(config.PostMatchHold))
+	updateMatch(time.Now())
+	if matchPhase != phasePlaying || winner.Points != 0 {
+		t.Fatalf("после показа итогов: фаза %s, очков %d", matchPhase, winner.Points)
+	}
+	if capturePoints[0].IsCaptured {
+		t.Fatal("точка осталась захваченной в новом матче")
+	}
+	if winner.X == 111 && winner.Y == 222 {
+		t.Fatal("игрок не возвращён на точку появления")
+	}
+	if _, ok := client.next(testTimeout, func(m map[string]interface{}) bool { return m["type"] == "phase" && m["phase"] == phasePlaying }); !ok {
+		t.Fatal("клиенты не узнали о новом матче")
+	}
+}
+
+func TestStopLongerThanIdleTimeoutKeepsPlayers(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		stop   func(now time.Time)
+		resume func(now time.Time)
+	}{
+		{"пауза администратора", func(now time.Time) { pauseMatch(now) }, func(now time.Time) { unpauseMatch(now) }},
+		{"ожидание игроков", func(now time.Time) {
+			config.MinPlayers = 3
+			updateMinPlayers(now)
+		}, func(now time.Time) {
+			config.MinPlayers = 0
+			updateMinPlayers(now)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGame(t)
+			first, _ := addTestPlayer(t, 100, 700)
+			second, _ := addTestPlayer(t, 900, 100)
+			idleTimeout := time.Duration(config.IdleTimeout)
+			start := time.Now()
+			first.LastActivity = start.Add(-idleTimeout / 2)
+			second.LastActivity = start
+
+			// Пока матч стоит, бездействие не проверяется
+			tt.stop(start)
+			stopped := start.Add(2 * idleTimeout)
+			reapTick(stopped)
+			if len(players) != 2 {
+				t.Fatal("игроки выкинуты как бездействующие во время остановки")
+			}
+
+			// Остановка не засчитывается в бездействие: первого игрока
+			// выкидывает через оставшуюся половину IdleTimeout
+			tt.resume(stopped)
+			reapTick(stopped.Add(idleTimeout / 4))
+			if len(players) != 2 {
+				t.Fatal("игроки выкинуты сразу после продолжения матча")
+			}
+			reapTick(stopped.Add(idleTimeout/2 + time.Second))
+			if _, ok := players[first.ID]; ok {
+				t.Fatal("бездействие до остановки не учтено")
+			}
+			if _, ok := players[second.ID]; !ok {
+				t.Fatal("второй игрок выкинут раньше времени")
+			}
+		})
+	}
+}
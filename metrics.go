@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Ошибки сериализации пишутся в лог не чаще раза в этот интервал
+const marshalErrorLogInterval = 10 * time.Second
+
+// Счётчики сервера. Увеличиваются без mutex и читаются /metrics без
+// блокировок, поэтому не добавляют ожидания в игровой цикл
+var metrics struct {
+	packetsReceived atomic.Int64 // Принятые пакеты
+	packetsDropped  atomic.Int64 // Пакеты, отброшенные из-за размера или переполненной очереди
+	joins           atomic.Int64 // Подключения игроков
+	leaves          atomic.Int64 // Удаления игроков
+	captures        atomic.Int64 // Захваты точек
+	pushes          atomic.Int64 // Попадания "push"
+	pulls           atomic.Int64 // Попадания "pull"
+
+	// Ошибки сериализации сообщений и состояния. Структуры сообщений простые,
+	// поэтому любая ошибка здесь — повод для тревоги
+	marshalErrors atomic.Int64
+}
+
+// Время последней записи об ошибке сериализации в лог, Unix-наносекунды
+var lastMarshalErrorLog atomic.Int64
+
+// recordMarshalError учитывает ошибку сериализации и пишет её в лог с
+// ограничением частоты, чтобы ошибка в каждом тике не заполнила лог
+func recordMarshalError(what string, err error) {
+	total := metrics.marshalErrors.Add(1)
+
+	now := time.Now().UnixNano()
+	last := lastMarshalErrorLog.Load()
+	if now-last < int64(marshalErrorLogInterval) || !lastMarshalErrorLog.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("Ошибка сериализации %s: %v (всего ошибок: %d)", what, err, total)
+}
+
+// HTTP-сервер метрик, останавливается в shutdown
+var metricsServer *http.Server
+
+// serveMetrics отдаёт счётчики сервера в текстовом формате Prometheus на
+// открытом listener. Сервер работает в отдельной горутине до shutdown
+func serveMetrics(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	server := &http.Server{Handler: mux}
+	metricsServer = server
+	log.Printf("Метрики доступны на http://%s/metrics", listener.Addr())
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			log.Println("Ошибка сервера метрик:", err)
+		}
+	}()
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintf(w, "game_bytes_sent_total %d\n", totalTraffic.sent.Load())
+	fmt.Fprintf(w, "game_bytes_received_total %d\n", totalTraffic.received.Load())
+	fmt.Fprintf(w, "game_packets_received_total %d\n", metrics.packetsReceived.Load())
+	fmt.Fprintf(w, "game_packets_dropped_total %d\n", metrics.packetsDropped.Load())
+	fmt.Fprintf(w, "game_joins_total %d\n", metrics.joins.Load())
+	fmt.Fprintf(w, "game_leaves_total %d\n", metrics.leaves.Load())
+	fmt.Fprintf(w, "game_captures_total %d\n", metrics.captures.Load())
+	fmt.Fprintf(w, "game_pushes_total %d\n", metrics.pushes.Load())
+	fmt.Fprintf(w, "game_pulls_total %d\n", metrics.pulls.Load())
+	fmt.Fprintf(w, "game_marshal_errors_total %d\n", metrics.marshalErrors.Load())
+
+	// Адреса сортируются, чтобы вывод был стабильным между запросами
+	var addrs []string
+	connTraffic.Range(func(key, _ interface{}) bool {
+		addrs = append(addrs, key.(string))
+		return true
+	})
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		t, ok := connTraffic.Load(addr)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "game_connection_bytes_sent_total{addr=%q} %d\n", addr, t.(*traffic).sent.Load())
+		fmt.Fprintf(w, "game_connection_bytes_received_total{addr=%q} %d\n", addr, t.(*traffic).received.Load())
+	}
+}
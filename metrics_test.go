@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMarshalErrorCounted(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	before := metrics.marshalErrors.Load()
+
+	// NaN не сериализуется в JSON: рассылка тика пропускается, но ошибка учтена
+	player.X = math.NaN()
+	gameTick(time.Now(), 10*time.Millisecond, true)
+	if got := metrics.marshalErrors.Load() - before; got != 1 {
+		t.Fatalf("ошибок сериализации учтено %d, ожидалась одна", got)
+	}
+	client.expectNone(100*time.Millisecond, isState)
+
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "game_marshal_errors_total") {
+		t.Fatalf("метрики без ошибок сериализации:\n%s", rec.Body)
+	}
+
+	// Следующий тик с корректным состоянием рассылается как обычно
+	player.X = 400
+	gameTick(time.Now(), 10*time.Millisecond, true)
+	client.recvState()
+}
+
+// TestMetricsConcurrentIncrements увеличивает счётчики из многих горутин
+// одновременно с чтением /metrics. Запускать с -race
+func TestMetricsConcurrentIncrements(t *testing.T) {
+	const goroutines, increments = 32, 1000
+	pushes, captures, received := metrics.pushes.Load(), metrics.captures.Load(), metrics.packetsReceived.Load()
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				metrics.pushes.Add(1)
+				metrics.captures.Add(1)
+				metrics.packetsReceived.Add(1)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			handleMetrics(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		}
+	}()
+	wg.Wait()
+	<-done
+
+	want := int64(goroutines * increments)
+	if got := metrics.pushes.Load() - pushes; got != want {
+		t.Errorf("pushes: %d, ожидалось %d", got, want)
+	}
+	if got := metrics.captures.Load() - captures; got != want {
+		t.Errorf("captures: %d, ожидалось %d", got, want)
+	}
+	if got := metrics.packetsReceived.Load() - received; got != want {
+		t.Errorf("packetsReceived: %d, ожидалось %d", got, want)
+	}
+}
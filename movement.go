@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// Допуск на сетевой джиттер при проверке скорости
+	moveTolerance = 10.0
+	// Дольше этого простоя перемещение не накапливается, иначе после паузы
+	// клиент мог бы телепортироваться на любое расстояние
+	maxMoveInterval = time.Second
+)
+
+// handleMovement применяет присланную клиентом позицию, вызывается под mutex.
+// Если включён буфер ввода, принятая позиция не применяется сразу, а
+// ставится в очередь, которую игровой тик разбирает по одной позиции за тик
+func handleMovement(player *Player, msg map[string]interface{}) {
+	// Пакеты разбираются несколькими обработчиками и могут прийти не по
+	// порядку, поэтому позиция, отправленная раньше уже принятой, отбрасывается:
+	// иначе игрок откатился бы назад
+	if seq, ok := msg["seq"].(float64); ok && hasPosition(msg) && player.LastInputSeq > 0 && int(seq) <= player.LastInputSeq {
+		logDebug("Игрок %d прислал устаревшую позицию (seq %d)", player.ID, int(seq))
+		return
+	}
+
+	baseX, baseY := lastAcceptedPosition(player)
+	newX, newY := baseX, baseY
+	if x, ok := msg["x"].(float64); ok && isFinite(x) {
+		newX = x
+	}
+	if y, ok := msg["y"].(float64); ok && isFinite(y) {
+		newY = y
+	}
+
+	if newX != baseX || newY != baseY {
+		now := player.LastSeen
+		if !isMoveAllowed(player, baseX, baseY, newX, newY, now) {
+			// Невозможное перемещение: возвращаем клиента в последнюю принятую позицию
+			log.Printf("Игрок %d переместился слишком быстро, отправлена коррекция", player.ID)
+			flushInputQueue(player)
+			sendCorrection(player)
+			return
+		}
+
+		player.LastActivity = now
+		// Движение снимает защиту после появления
+		player.ProtectedUntil = time.Time{}
+		player.LastMoveTime = now
+		player.KnockbackShift = 0
+
+		if size := config.InputBufferSize; size > 0 {
+			// Переполненная очередь догоняет ввод: самая старая позиция применяется сразу
+			if len(player.InputQueue) >= size {
+				applyMove(player, player.InputQueue[0].X, player.InputQueue[0].Y)
+				player.InputQueue = player.InputQueue[1:]
+			}
+			player.InputQueue = append(player.InputQueue, Point{X: newX, Y: newY})
+		} else {
+			applyMove(player, newX, newY)
+		}
+	}
+
+	if seq, ok := msg["seq"].(float64); ok && int(seq) > player.LastInputSeq {
+		player.LastInputSeq = int(seq)
+	}
+}
+
+// applyMove перемещает игрока с учётом препятствий, вызывается под mutex
+func applyMove(player *Player, x, y float64) {
+	// Препятствия не пускают игрока внутрь
+	oldX, oldY := player.X, player.Y
+	player.X, player.Y, _ = moveWithCollision(player.X, player.Y, x, y)
+	player.Stats.Distance += math.Hypot(player.X-oldX, player.Y-oldY)
+}
+
+// Шаг, которым рывок проверяет препятствия на пути: moveWithCollision
+// смотрит только на конечную позицию, и за длинный шаг игрок проскочил бы
+// сквозь тонкую стену
+const dashStep = 1.0
+
+// applyDash переносит игрока на DashDistance по направлению Facing. Рывок
+// останавливается у препятствия или границы мира. Клиент получает коррекцию
+// с новой позицией. Вызывается под mutex
+func applyDash(player *Player, now time.Time) {
+	// Рывок начинается с последней принятой позиции
+	flushInputQueue(player)
+	dirX, dirY := math.Cos(player.Facing), math.Sin(player.Facing)
+	oldX, oldY := player.X, player.Y
+	for moved := 0.0; moved < config.DashDistance; moved += dashStep {
+		step := min(dashStep, config.DashDistance-moved)
+		var blocked bool
+		player.X, player.Y, blocked = moveWithCollision(player.X, player.Y, player.X+dirX*step, player.Y+dirY*step)
+		if blocked {
+			break
+		}
+	}
+	player.Stats.Distance += math.Hypot(player.X-oldX, player.Y-oldY)
+	player.ProtectedUntil = time.Time{}
+	player.LastMoveTime = now
+	sendCorrection(player)
+}
+
+// lastAcceptedPosition возвращает последнюю принятую от клиента позицию:
+// конец очереди ввода или текущую позицию, вызывается под mutex
+func lastAcceptedPosition(player *Player) (float64, float64) {
+	if n := len(player.InputQueue); n > 0 {
+		return player.InputQueue[n-1].X, player.InputQueue[n-1].Y
+	}
+	return player.X, player.Y
+}
+
+// flushInputQueue сразу применяет все ожидающие позиции игрока, вызывается под mutex
+func flushInputQueue(player *Player) {
+	for _, p := range player.InputQueue {
+		applyMove(player, p.X, p.Y)
+	}
+	player.InputQueue = player.InputQueue[:0]
+}
+
+// drainInputQueues применяет по одной ожидающей позиции каждого игрока, чтобы
+// пачка пакетов, пришедших из-за джиттера одновременно, превратилась в
+// равномерное движение. Вызывается под mutex из игрового тика
+func drainInputQueues() {
+	for _, player := range players {
+		if len(player.InputQueue) == 0 {
+			continue
+		}
+		applyMove(player, player.InputQueue[0].X, player.InputQueue[0].Y)
+		player.InputQueue = player.InputQueue[1:]
+	}
+}
+
+// isMoveAllowed проверяет, что игрок не превысил MaxSpeed (с учётом скина и
+// усиления speed) с прошлого перемещения
+func isMoveAllowed(player *Player, fromX, fromY, x, y float64, now time.Time) bool {
+	if config.MaxSpeed <= 0 {
+		return true
+	}
+	elapsed := min(now.Sub(player.LastMoveTime), maxMoveInterval)
+	speed := config.MaxSpeed * player.Attrs.Speed * (1 + buffMagnitude(player, buffSpeed, now))
+	// Ограничивается только собственное движение: отброс может унести игрока
+	// дальше, чем он способен пробежать, и это не нарушение
+	allowed := speed*elapsed.Seconds() + moveTolerance + player.KnockbackShift
+	return math.Hypot(x-fromX, y-fromY) <= allowed
+}
+
+// sendCorrection сообщает клиенту авторитетную позицию и номер последнего
+// принятого ввода, чтобы клиент мог переиграть неподтверждённые вводы
+func sendCorrection(player *Player) {
+	sendToPlayer(player.ID, map[string]interface{}{
+		"type": "correction",
+		"x":    player.X,
+		"y":    player.Y,
+		"seq":  player.LastInputSeq,
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleMoveDropped(t *testing.T) {
+	resetGame(t)
+	player, _ := addTestPlayer(t, 400, 400)
+
+	handleMovement(player, map[string]interface{}{"x": 420.0, "y": 400.0, "seq": 5.0})
+	// Позиция с меньшим номером пришла позже: она устарела
+	handleMovement(player, map[string]interface{}{"x": 410.0, "y": 400.0, "seq": 4.0})
+	handleMovement(player, map[string]interface{}{"x": 415.0, "y": 400.0, "seq": 5.0})
+	if player.X != 420 || player.LastInputSeq != 5 {
+		t.Fatalf("игрок в x=%v, seq=%d, ожидалось x=420, seq=5", player.X, player.LastInputSeq)
+	}
+
+	handleMovement(player, map[string]interface{}{"x": 430.0, "y": 400.0, "seq": 6.0})
+	if player.X != 430 || player.LastInputSeq != 6 {
+		t.Fatalf("новая позиция не принята: x=%v, seq=%d", player.X, player.LastInputSeq)
+	}
+}
+
+func TestMoveWithoutSeqAlwaysApplied(t *testing.T) {
+	resetGame(t)
+	player, _ := addTestPlayer(t, 400, 400)
+
+	handleMovement(player, map[string]interface{}{"x": 420.0, "y": 400.0})
+	handleMovement(player, map[string]interface{}{"x": 410.0, "y": 400.0, "seq": 0.0})
+	if player.X != 410 {
+		t.Fatalf("перемещение без номера не применено: x=%v", player.X)
+	}
+}
+
+func TestMoveGetsNoImmediateState(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	// Ответ на подключение уже пришёл и больше не мешает
+	client.recv("joined")
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "x": 410.0, "y": 400.0})
+	if player.X != 410 {
+		t.Fatalf("перемещение не применено: x=%v", player.X)
+	}
+	// Состояние рассылает только игровой цикл, на само перемещение ответа нет
+	client.expectNone(100*time.Millisecond, func(map[string]interface{}) bool { return true })
+}
+
+func TestTooFastMoveCorrected(t *testing.T) {
+	resetGame(t)
+	config.MaxSpeed = 100
+	player, client := addTestPlayer(t, 400, 400)
+	player.LastSeen = time.Now()
+
+	handleMovement(player, map[string]interface{}{"x": 405.0, "y": 400.0, "seq": 1.0})
+	handleMovement(player, map[string]interface{}{"x": 900.0, "y": 400.0, "seq": 2.0})
+	if player.X != 405 {
+		t.Fatalf("невозможное перемещение принято: x=%v", player.X)
+	}
+
+	// Коррекция возвращает клиента в последнюю принятую позицию
+	correction := client.recv("correction")
+	if msgFloat(t, correction, "x") != 405 || msgFloat(t, correction, "y") != 400 || msgFloat(t, correction, "seq") != 1 {
+		t.Fatalf("коррекция %v", correction)
+	}
+}
+
+func TestBurstInputSmoothedAcrossTicks(t *testing.T) {
+	resetGame(t)
+	config.InputBufferSize = 4
+	player, _ := addTestPlayer(t, 400, 400)
+
+	// Три позиции пришли одной пачкой
+	for i := 1; i <= 3; i++ {
+		handleMovement(player, map[string]interface{}{"x": 400 + 10*float64(i), "y": 400.0, "seq": float64(i)})
+	}
+	if player.X != 400 {
+		t.Fatalf("пачка применена сразу: x=%v", player.X)
+	}
+
+	// Каждый тик применяет по одной позиции, поэтому шаги равные
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		now = now.Add(10 * time.Millisecond)
+		gameTick(now, 10*time.Millisecond, false)
+		want := 400 + 10*float64(min(i, 3))
+		if player.X != want {
+			t.Fatalf("тик %d: x=%v, ожидалось %v", i, player.X, want)
+		}
+	}
+}
+
+func TestSnapshotCarriesLastProcessedInput(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	for seq := 1; seq <= 7; seq++ {
+		handleMovement(player, map[string]interface{}{"x": 400 + float64(seq), "y": 400.0, "seq": float64(seq)})
+	}
+
+	gameTick(time.Now(), 10*time.Millisecond, true)
+	state := client.recvState()
+	list, _ := state["players"].([]interface{})
+	if len(list) != 1 {
+		t.Fatalf("игроки в состоянии: %v", list)
+	}
+	if got := msgFloat(t, list[0].(map[string]interface{}), "lastProcessedInput"); got != 7 {
+		t.Fatalf("lastProcessedInput = %v, ожидалось 7", got)
+	}
+}
+
+func TestSpeedCapIgnoresKnockback(t *testing.T) {
+	resetGame(t)
+	config.MaxSpeed = 100
+	player, _ := addTestPlayer(t, 400, 400)
+	pusher, _ := addTestPlayer(t, 350, 400)
+	now := time.Now()
+	player.LastSeen, player.LastMoveTime = now, now.Add(-100*time.Millisecond)
+
+	// За 100 мс можно пробежать 10 единиц и ещё moveTolerance
+	handleMovement(player, map[string]interface{}{"x": 400 + 10 + moveTolerance + 5, "y": 400.0})
+	if player.X != 400 {
+		t.Fatalf("собственное перемещение быстрее MaxSpeed принято: x=%v", player.X)
+	}
+	handleMovement(player, map[string]interface{}{"x": 410.0, "y": 400.0})
+	if player.X != 410 {
+		t.Fatalf("допустимое перемещение отклонено: x=%v", player.X)
+	}
+
+	// Отброс за один тик уносит игрока дальше, чем он может пробежать
+	applyPush(pusher)
+	x := player.X
+	integrateKnockback(time.Now().Add(knockbackStep), knockbackStep)
+	if step := player.X - x; step <= config.MaxSpeed*knockbackStep.Seconds() {
+		t.Fatalf("отброс за тик %v не быстрее MaxSpeed", step)
+	}
+	stepKnockback(t, player)
+
+	// Клиент, ещё не узнавший об отбросе, присылает прежнюю позицию. Разница
+	// с сервером больше moveTolerance, но это не нарушение скорости
+	if player.X-x < moveTolerance {
+		t.Fatalf("отброс слишком слабый для проверки: %v", player.X-x)
+	}
+	player.LastSeen = player.LastMoveTime
+	handleMovement(player, map[string]interface{}{"x": x, "y": 400.0})
+	if player.X != x {
+		t.Fatalf("позиция после отброса отклонена: x=%v, ожидалось %v", player.X, x)
+	}
+}
+
+func TestMoveFloodDropped(t *testing.T) {
+	resetGame(t)
+	config.MoveRateLimit = RateLimit{Rate: 10, Burst: 5}
+	config.ActionRateLimit = RateLimit{Rate: 10, Burst: 5}
+	player, client := addTestPlayer(t, 400, 400)
+
+	for i := 1; i <= 50; i++ {
+		handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "x": 400 + float64(i), "y": 400.0})
+	}
+	// Проходит запас burst и то, что успело пополниться за время цикла
+	if player.X < 405 || player.X > 410 {
+		t.Fatalf("после потока из 50 перемещений x=%v, ожидалось около 405", player.X)
+	}
+
+	// Поток перемещений не тратит бюджет действий
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "action": "status"})
+	client.recv("status")
+}
@@ -0,0 +1,120 @@
+package main
+
+import "math"
+
+// Obstacle — прямоугольное препятствие, (X, Y) — левый верхний угол
+type Obstacle struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// segmentIntersectsRect проверяет, пересекает ли отрезок (x1, y1)-(x2, y2)
+// прямоугольник препятствия (алгоритм Лианга-Барски)
+func segmentIntersectsRect(x1, y1, x2, y2 float64, o Obstacle) bool {
+	dx := x2 - x1
+	dy := y2 - y1
+	p := [4]float64{-dx, dx, -dy, dy}
+	q := [4]float64{x1 - o.X, o.X + o.Width - x1, y1 - o.Y, o.Y + o.Height - y1}
+
+	t0, t1 := 0.0, 1.0
+	for i := 0; i < 4; i++ {
+		if p[i] == 0 {
+			// Отрезок параллелен стороне и лежит снаружи
+			if q[i] < 0 {
+				return false
+			}
+			continue
+		}
+		t := q[i] / p[i]
+		if p[i] < 0 {
+			if t > t1 {
+				return false
+			}
+			if t > t0 {
+				t0 = t
+			}
+		} else {
+			if t < t0 {
+				return false
+			}
+			if t < t1 {
+				t1 = t
+			}
+		}
+	}
+	return true
+}
+
+// isLineOfSightBlocked проверяет, перекрывает ли какое-либо препятствие линию между точками
+func isLineOfSightBlocked(x1, y1, x2, y2 float64) bool {
+	for _, o := range config.Obstacles {
+		if segmentIntersectsRect(x1, y1, x2, y2, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains проверяет, находится ли точка строго внутри препятствия
+func (o Obstacle) contains(x, y float64) bool {
+	return x > o.X && x < o.X+o.Width && y > o.Y && y < o.Y+o.Height
+}
+
+// nearestEdge возвращает ближайшую к точке позицию на границе препятствия
+func (o Obstacle) nearestEdge(x, y float64) (float64, float64) {
+	left := x - o.X
+	right := o.X + o.Width - x
+	top := y - o.Y
+	bottom := o.Y + o.Height - y
+
+	switch math.Min(math.Min(left, right), math.Min(top, bottom)) {
+	case left:
+		return o.X, y
+	case right:
+		return o.X + o.Width, y
+	case top:
+		return x, o.Y
+	default:
+		return x, o.Y + o.Height
+	}
+}
+
+// distanceTo возвращает расстояние от точки до препятствия (0 — внутри)
+func (o Obstacle) distanceTo(x, y float64) float64 {
+	dx := max(o.X-x, 0, x-(o.X+o.Width))
+	dy := max(o.Y-y, 0, y-(o.Y+o.Height))
+	return math.Hypot(dx, dy)
+}
+
+// moveWithCollision перемещает точку из (fromX, fromY) в (toX, toY). Если конечная
+// позиция оказывается внутри препятствия, она прижимается к стороне, через которую
+// точка вошла, а движение вдоль этой стороны сохраняется (скольжение).
+// blocked сообщает, было ли перемещение ограничено препятствием или границей мира
+func moveWithCollision(fromX, fromY, toX, toY float64) (x, y float64, blocked bool) {
+	// Граница мира работает как стена
+	x, y = clampToWorld(toX, toY)
+	blocked = x != toX || y != toY
+	for _, o := range config.Obstacles {
+		if !o.contains(x, y) {
+			continue
+		}
+		blocked = true
+
+		switch {
+		case fromX <= o.X:
+			x = o.X
+		case fromX >= o.X+o.Width:
+			x = o.X + o.Width
+		case fromY <= o.Y:
+			y = o.Y
+		case fromY >= o.Y+o.Height:
+			y = o.Y + o.Height
+		default:
+			// Начальная точка уже внутри, выталкиваем к ближайшему краю
+			x, y = o.nearestEdge(x, y)
+		}
+	}
+	return x, y, blocked
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestSegmentIntersectsRect(t *testing.T) {
+	o := Obstacle{X: 10, Y: 10, Width: 10, Height: 10}
+	tests := []struct {
+		name           string
+		x1, y1, x2, y2 float64
+		want           bool
+	}{
+		{"насквозь", 0, 15, 30, 15, true},
+		{"мимо", 0, 0, 30, 5, false},
+		{"не доходит", 0, 15, 9, 15, false},
+		{"по диагонали", 0, 0, 30, 30, true},
+		{"вертикально рядом", 25, 0, 25, 30, false},
+	}
+	for _, tt := range tests {
+		if got := segmentIntersectsRect(tt.x1, tt.y1, tt.x2, tt.y2, o); got != tt.want {
+			t.Errorf("%s: получено %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPushBlockedByObstacle(t *testing.T) {
+	resetGame(t)
+	config.Obstacles = []Obstacle{{X: 440, Y: 380, Width: 20, Height: 40}}
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 480, 400)
+
+	applyPush(actor)
+	applyPull(actor)
+	if isKnockedBack(target) || actor.Stats.PushesLanded != 0 || actor.Stats.PullsLanded != 0 {
+		t.Fatalf("цель за препятствием отброшена: v=(%v, %v)", target.VX, target.VY)
+	}
+
+	// Без препятствия на линии толчок проходит
+	config.Obstacles = []Obstacle{{X: 440, Y: 300, Width: 20, Height: 40}}
+	applyPush(actor)
+	if target.VX <= 0 || actor.Stats.PushesLanded != 1 {
+		t.Fatalf("толчок по открытой линии не прошёл: v=(%v, %v)", target.VX, target.VY)
+	}
+}
+
+func TestMoveStopsAtObstacleEdge(t *testing.T) {
+	resetGame(t)
+	config.Obstacles = []Obstacle{{X: 420, Y: 350, Width: 40, Height: 100}}
+
+	if x, y, blocked := moveWithCollision(400, 400, 450, 410); x != 420 || y != 410 || !blocked {
+		t.Fatalf("упёрся в (%v, %v), blocked=%v, ожидалось (420, 410)", x, y, blocked)
+	}
+
+	// Через сообщение клиента игрок тоже останавливается на краю
+	player, _ := addTestPlayer(t, 400, 400)
+	handleMovement(player, map[string]interface{}{"x": 440.0, "y": 400.0})
+	if player.X != 420 || player.Y != 400 {
+		t.Fatalf("игрок в (%v, %v), ожидалось (420, 400)", player.X, player.Y)
+	}
+}
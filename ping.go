@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Коэффициент сглаживания задержки (экспоненциальное скользящее среднее)
+const pingSmoothing = 0.2
+
+// pingLoop периодически рассылает игрокам ping; клиент отвечает сообщением
+// {"type":"pong","t":...}, возвращая полученную метку времени
+func pingLoop() {
+	for {
+		mutex.Lock()
+		interval := time.Duration(config.PingInterval)
+		timeout := time.Duration(config.PlayerTimeout)
+		now := time.Now()
+		msg := map[string]interface{}{
+			"type": "ping",
+			"t":    now.UnixNano(),
+		}
+		for id, player := range players {
+			countHeartbeat(player, now, timeout)
+			if addr, ok := clientAddrs[id]; ok {
+				sendUDPMessage(addr, msg)
+			}
+		}
+		for _, spectator := range spectators {
+			sendUDPMessage(spectator.Addr, msg)
+		}
+		mutex.Unlock()
+
+		if !waitTick(interval) {
+			return
+		}
+	}
+}
+
+// countHeartbeat считает интервалы ping подряд, пропущенные игроком сверх
+// PlayerTimeout: короткая потеря пакетов до таймаута в счёт не идёт. Любое
+// сообщение обнуляет счёт. Вызывается под mutex
+func countHeartbeat(player *Player, now time.Time, timeout time.Duration) {
+	if timeout > 0 && now.Sub(player.LastSeen) > timeout {
+		player.MissedHeartbeats++
+	} else {
+		player.MissedHeartbeats = 0
+	}
+}
+
+// handlePong обрабатывает ответ клиента на ping
+func handlePong(player *Player, msg map[string]interface{}) {
+	t, ok := msg["t"].(float64)
+	if !ok {
+		return
+	}
+	updatePing(player, time.Since(time.Unix(0, int64(t))))
+}
+
+// updatePing обновляет сглаженную задержку игрока по новому замеру RTT.
+// До первого замера Ping равен -1
+func updatePing(player *Player, rtt time.Duration) {
+	if rtt < 0 {
+		return
+	}
+	ms := float64(rtt) / float64(time.Millisecond)
+	if player.Ping < 0 {
+		player.Ping = int(math.Round(ms))
+		return
+	}
+	player.Ping = int(math.Round(float64(player.Ping)*(1-pingSmoothing) + ms*pingSmoothing))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdatePing(t *testing.T) {
+	player := &Player{Ping: -1}
+
+	updatePing(player, 40*time.Millisecond)
+	if player.Ping != 40 {
+		t.Fatalf("первый замер: %d мс, ожидалось 40", player.Ping)
+	}
+	// Следующие замеры сглаживаются
+	updatePing(player, 80*time.Millisecond)
+	if player.Ping != 48 {
+		t.Fatalf("сглаженная задержка: %d мс, ожидалось 48", player.Ping)
+	}
+	updatePing(player, -time.Millisecond)
+	if player.Ping != 48 {
+		t.Fatalf("отрицательный замер изменил задержку: %d", player.Ping)
+	}
+}
+
+func TestPongSetsPing(t *testing.T) {
+	player := &Player{Ping: -1}
+	sent := time.Now().Add(-30 * time.Millisecond)
+	handlePong(player, map[string]interface{}{"type": "pong", "t": float64(sent.UnixNano())})
+	if player.Ping < 30 || player.Ping > 40 {
+		t.Fatalf("задержка %d мс, ожидалось около 30", player.Ping)
+	}
+}
+
+// TestHeartbeatGraceWindow прогоняет ping и проверку молчащих игроков по
+// секундам: удалить можно только игрока, пропустившего HeartbeatGrace
+// интервалов подряд уже после PlayerTimeout
+func TestHeartbeatGraceWindow(t *testing.T) {
+	resetGame(t)
+	config.IdleTimeout = 0
+	interval := time.Second
+	timeout := time.Duration(config.PlayerTimeout)
+	start := time.Now()
+	lossy, _ := addTestPlayer(t, 100, 100)
+	silent, _ := addTestPlayer(t, 700, 700)
+	lossy.LastSeen, silent.LastSeen = start, start
+
+	// Последний интервал, после которого молчащего игрока ещё нельзя удалить
+	last := timeout/interval + time.Duration(config.HeartbeatGrace) - 1
+	for i := time.Duration(1); i <= last+1; i++ {
+		now := start.Add(i * interval)
+		if i == timeout/interval+2 {
+			// Игрок пропустил один ping сверх таймаута и снова на связи
+			if lossy.MissedHeartbeats != 1 {
+				t.Fatalf("пропущено %d интервалов, ожидался один", lossy.MissedHeartbeats)
+			}
+			lossy.LastSeen = now.Add(-time.Millisecond)
+		}
+		for _, player := range players {
+			countHeartbeat(player, now, timeout)
+		}
+		reapTick(now)
+		if _, ok := players[silent.ID]; ok != (i <= last) {
+			t.Fatalf("через %d интервалов молчания игрок на сервере: %v (пропущено %d)", i, ok, silent.MissedHeartbeats)
+		}
+	}
+	if _, ok := players[lossy.ID]; !ok {
+		t.Fatal("игрок, пропустивший один ping и вернувшийся, удалён")
+	}
+	if lossy.MissedHeartbeats != 0 {
+		t.Fatalf("после возвращения пропущено %d", lossy.MissedHeartbeats)
+	}
+}
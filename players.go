@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// Point — точка на карте
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Единственный источник случайности в игре. Все случайные решения берутся
+// отсюда, поэтому при одинаковом Seed матч воспроизводится одинаково
+var rng = rand.New(rand.NewSource(1))
+
+// Часы игры. Вся игровая логика берёт текущее время отсюда, поэтому тесты
+// подставляют фиктивные часы и вместе с Seed получают повторяемый матч
+var clock = time.Now
+
+// seedRNG пересоздаёт генератор по настройке Seed (0 — случайное зерно)
+func seedRNG(seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng = rand.New(rand.NewSource(seed))
+	log.Printf("Зерно генератора случайных чисел: %d", seed)
+}
+
+// Цвет нейтральной точки захвата
+const neutralColor = "#9e9e9e"
+
+// Палитра цветов игроков, назначаемых по кругу
+var playerColors = []string{
+	"#e53935", "#1e88e5", "#43a047", "#fdd835",
+	"#8e24aa", "#fb8c00", "#00acc1", "#d81b60",
+}
+
+// playerColor возвращает цвет для игрока с указанным ID
+func playerColor(playerID int) string {
+	return playerColors[(playerID-1)%len(playerColors)]
+}
+
+// spawnPosition выбирает точку появления игрока команды team: из точек
+// команды в TeamSpawns, а если их нет или игра без команд — из общих.
+// Вызывается под mutex
+func spawnPosition(team int) (float64, float64) {
+	spawns := config.SpawnPoints
+	if teamSpawns := config.TeamSpawns[team]; team > 0 && len(teamSpawns) > 0 {
+		spawns = teamSpawns
+	}
+	if len(spawns) == 0 {
+		return 400 * config.WorldWidth / referenceWorldWidth, 400 * config.WorldHeight / referenceWorldHeight
+	}
+	spawn := spawns[rng.Intn(len(spawns))]
+	return clampToWorld(spawn.X, spawn.Y)
+}
+
+// removePlayer удаляет игрока из игры и освобождает принадлежащие ему точки,
+// вызывается под mutex
+func removePlayer(playerID int, reason string) {
+	if _, ok := players[playerID]; !ok {
+		return
+	}
+	delete(players, playerID)
+	// Без этого gameLoop продолжал бы слать состояние на адрес ушедшего игрока
+	if addr, ok := clientAddrs[playerID]; ok {
+		key := addr.String()
+		addrPlayers[key] = slices.DeleteFunc(addrPlayers[key], func(id int) bool { return id == playerID })
+		if len(addrPlayers[key]) == 0 {
+			delete(addrPlayers, key)
+			if _, ok := spectators[key]; !ok {
+				untrackConnection(key)
+				closeDTLSSession(key)
+			}
+		}
+	}
+	delete(clientAddrs, playerID)
+	delete(writeFailures, playerID)
+	purgeReliable(playerID)
+
+	for i := range capturePoints {
+		cp := &capturePoints[i]
+		if cp.CapturingPlayer == playerID {
+			neutralizePoint(cp, "owner_left")
+		}
+		if cp.CurrentCapturingPlayer == playerID {
+			cp.CurrentCapturingPlayer = 0
+			cp.EnterTime = time.Time{}
+		}
+	}
+
+	broadcastReliable(map[string]interface{}{
+		"type":   "event",
+		"event":  "leave",
+		"id":     playerID,
+		"reason": reason,
+	}, playerID)
+	metrics.leaves.Add(1)
+	log.Printf("Игрок %d удалён (%s)", playerID, reason)
+
+	promoteWaiting()
+	rebalanceTeams()
+}
+
+// playerLimit возвращает наибольшее число игроков: MaxPlayers, а если он не
+// задан — сколько помещается в одно состояние игры. Вызывается под mutex
+func playerLimit() int {
+	if config.MaxPlayers > 0 {
+		return config.MaxPlayers
+	}
+	return maxStatePlayers
+}
+
+// unreachableError сообщает, что отправка не удалась из-за адреса получателя.
+// Ошибки размера датаграммы и переполнения буфера от адреса не зависят и
+// игрока не отключают
+func unreachableError(err error) bool {
+	var addrErr *net.AddrError
+	return errors.As(err, &addrErr) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.EHOSTDOWN) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL)
+}
+
+// recordWriteFailure учитывает неудачную отправку игроку. Если адрес стал
+// недоступен, игрок считается отключившимся. Вызывается под mutex
+func recordWriteFailure(playerID int) {
+	writeFailures[playerID]++
+	if config.MaxWriteFailures > 0 && writeFailures[playerID] >= config.MaxWriteFailures {
+		removePlayer(playerID, "unreachable")
+	}
+}
+
+// applyWallDamage наносит урон игроку, которого впечатали в препятствие.
+// lost — смещение, погашенное препятствием. Вызывается под mutex
+func applyWallDamage(player *Player, lost float64) {
+	damage := int(math.Round(lost * config.WallDamage))
+	if damage <= 0 {
+		return
+	}
+	player.HP -= damage
+	log.Printf("Игрок %d получил %d урона от удара о препятствие", player.ID, damage)
+
+	if player.HP <= 0 {
+		respawnPlayer(player)
+	}
+}
+
+// respawnPlayer возвращает игрока на точку появления с полным здоровьем,
+// вызывается под mutex
+func respawnPlayer(player *Player) {
+	player.X, player.Y = spawnPosition(player.Team)
+	player.HP = config.MaxHP
+	stopKnockback(player)
+	player.InputQueue = nil
+	player.ProtectedUntil = clock().Add(time.Duration(config.SpawnProtection))
+
+	broadcastEvent(map[string]interface{}{
+		"type": "respawn",
+		"id":   player.ID,
+		"x":    player.X,
+		"y":    player.Y,
+	})
+	log.Printf("Игрок %d возродился", player.ID)
+}
+
+// isProtected проверяет, действует ли на игрока защита после появления или
+// усиление shield
+func isProtected(player *Player, now time.Time) bool {
+	return now.Before(player.ProtectedUntil) || hasBuff(player, buffShield, now)
+}
+
+// isFrozen проверяет, заморожен ли игрок
+func isFrozen(player *Player, now time.Time) bool {
+	return now.Before(player.FrozenUntil)
+}
+
+// isAFK проверяет, бездействует ли игрок дольше AfkScoreTimeout. Такой игрок
+// не получает очков за точки, вызывается под mutex
+func isAFK(player *Player, now time.Time) bool {
+	timeout := time.Duration(config.AfkScoreTimeout)
+	return timeout > 0 && now.Sub(player.LastActivity) > timeout
+}
+
+// heartbeatLost проверяет, пора ли удалить молчащего игрока. С HeartbeatGrace
+// одна потерянная посылка не приводит к отключению: игрок должен пропустить
+// столько интервалов ping подряд. Вызывается под mutex
+func heartbeatLost(player *Player, now time.Time, timeout time.Duration) bool {
+	if timeout <= 0 || now.Sub(player.LastSeen) <= timeout {
+		return false
+	}
+	return config.HeartbeatGrace <= 0 || player.MissedHeartbeats >= config.HeartbeatGrace
+}
+
+// reapPlayers удаляет игроков, от которых давно не было сообщений, и
+// выкидывает бездействующих (AFK), которые только отвечают на ping
+func reapPlayers() {
+	for waitTick(time.Second) {
+		mutex.Lock()
+		reapTick(clock())
+		mutex.Unlock()
+	}
+}
+
+// reapTick выполняет одну проверку reapPlayers, вызывается под mutex
+func reapTick(now time.Time) {
+	timeout := time.Duration(config.PlayerTimeout)
+	idleTimeout := time.Duration(config.IdleTimeout)
+	for id, player := range players {
+		switch {
+		case heartbeatLost(player, now, timeout):
+			removePlayer(id, "timeout")
+		case idleTimeout > 0 && matchActive() && now.Sub(player.LastActivity) > idleTimeout:
+			sendToPlayer(id, map[string]interface{}{
+				"type":   "kicked",
+				"reason": "idle",
+			})
+			removePlayer(id, "idle")
+		}
+	}
+	reapSpectators(now)
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdlePlayerKickedDespitePings(t *testing.T) {
+	resetGame(t)
+	idle, idleClient := addTestPlayer(t, 100, 100)
+	active, activeClient := addTestPlayer(t, 700, 700)
+	idleTimeout := time.Duration(config.IdleTimeout)
+	idle.LastActivity = time.Now().Add(-idleTimeout - time.Second)
+	active.LastActivity = idle.LastActivity
+
+	// Ответы на ping и запросы статуса продлевают подключение, но не игру
+	handleUDPMessage(idleClient.addr(), map[string]interface{}{"type": "pong", "id": float64(idle.ID), "t": float64(time.Now().UnixNano())})
+	handleUDPMessage(idleClient.addr(), map[string]interface{}{"id": float64(idle.ID), "action": "status"})
+	// Атакующее действие — игра, даже если цели рядом нет
+	handleUDPMessage(activeClient.addr(), map[string]interface{}{"id": float64(active.ID), "action": "push"})
+
+	reapTick(time.Now())
+	if _, ok := players[idle.ID]; ok {
+		t.Fatal("бездействующий игрок, отвечающий на ping, не выкинут")
+	}
+	if kicked := idleClient.recv("kicked"); kicked["reason"] != "idle" {
+		t.Fatalf("причина: %v", kicked["reason"])
+	}
+	if _, ok := players[active.ID]; !ok {
+		t.Fatal("игрок, применивший действие, выкинут как бездействующий")
+	}
+}
+
+func TestRemovePlayerCleansAddresses(t *testing.T) {
+	resetGame(t)
+	gone, goneClient := addTestPlayer(t, 100, 100)
+	_, stayClient := addTestPlayer(t, 700, 700)
+
+	removePlayer(gone.ID, "left")
+	if _, ok := clientAddrs[gone.ID]; ok {
+		t.Fatal("адрес ушедшего игрока остался в clientAddrs")
+	}
+	if ids, ok := addrPlayers[goneClient.addr().String()]; ok {
+		t.Fatalf("адрес ушедшего игрока остался в addrPlayers: %v", ids)
+	}
+
+	// Рассылка состояния больше не доходит до ушедшего игрока
+	gameTick(time.Now(), 10*time.Millisecond, true)
+	stayClient.recvState()
+	goneClient.expectNone(100*time.Millisecond, isState)
+}
+
+// simulateMatch разыгрывает один и тот же набор действий с зерном seed на
+// фиктивных часах и возвращает итоговое состояние игры в JSON вместе с
+// позициями игроков по ID
+func simulateMatch(t *testing.T, seed int64) (state string, positions []Point) {
+	resetGame(t)
+	fakeNow := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return fakeNow }
+	config.Seed = seed
+	config.SpawnPoints = []Point{{X: 100, Y: 100}, {X: 900, Y: 100}, {X: 100, Y: 700}, {X: 900, Y: 700}, {X: 450, Y: 650}}
+	config.ActivePoints = 1
+	config.RotationInterval = Duration(time.Minute)
+	config.SpawnProtection = 0
+	seedRNG(config.Seed)
+	startMatch()
+
+	client := newTestClient(t)
+	for i := 0; i < 6; i++ {
+		addPlayer(client.addr(), joinRequest{encoding: encodingJSON})
+	}
+	for id := 1; id <= 6; id++ {
+		player := players[id]
+		handleMovement(player, map[string]interface{}{"x": player.X + float64(id*7), "y": player.Y - float64(id*3), "seq": 1.0})
+	}
+	for id := 1; id <= 6; id++ {
+		applyPush(players[id])
+	}
+	for i := 0; i < 30; i++ {
+		fakeNow = fakeNow.Add(knockbackStep)
+		gameTick(fakeNow, knockbackStep, false)
+		updateCapturePoints()
+	}
+	for i := 0; i < 3; i++ {
+		fakeNow = fakeNow.Add(time.Duration(config.RotationInterval))
+		rotateActivePoints(fakeNow)
+	}
+
+	for id := 1; id <= 6; id++ {
+		positions = append(positions, Point{X: players[id].X, Y: players[id].Y})
+	}
+	data, err := json.Marshal(currentGameState(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), positions
+}
+
+func TestSameSeedReplaysIdentically(t *testing.T) {
+	state, positions := simulateMatch(t, 42)
+	againState, _ := simulateMatch(t, 42)
+	if state != againState {
+		t.Fatalf("повтор с тем же зерном разошёлся:\n%s\n%s", state, againState)
+	}
+
+	// Другое зерно даёт другие точки появления
+	_, otherPositions := simulateMatch(t, 7)
+	if slices.Equal(positions, otherPositions) {
+		t.Fatal("разные зёрна дали одинаковый матч")
+	}
+}
+
+func TestSpawnProtectionBlocksPush(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+
+	target.ProtectedUntil = time.Now().Add(time.Hour)
+	applyPush(actor)
+	if isKnockedBack(target) {
+		t.Fatal("защищённый игрок отброшен")
+	}
+
+	// Истёкшая защита больше не спасает
+	target.ProtectedUntil = time.Now().Add(-time.Millisecond)
+	applyPush(actor)
+	if !isKnockedBack(target) {
+		t.Fatal("игрок с истёкшей защитой не отброшен")
+	}
+}
+
+func TestMovingDropsSpawnProtection(t *testing.T) {
+	resetGame(t)
+	actor, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+
+	target.ProtectedUntil = time.Now().Add(time.Hour)
+	target.LastSeen = time.Now()
+	handleMovement(target, map[string]interface{}{"x": 451.0, "y": 400.0})
+	if isProtected(target, time.Now()) {
+		t.Fatal("движение не сняло защиту")
+	}
+	applyPush(actor)
+	if !isKnockedBack(target) {
+		t.Fatal("игрок, снявший защиту движением, не отброшен")
+	}
+}
+
+func TestAttackDropsSpawnProtection(t *testing.T) {
+	resetGame(t)
+	protected, client := addTestPlayer(t, 400, 400)
+	enemy, _ := addTestPlayer(t, 450, 400)
+	protected.ProtectedUntil = time.Now().Add(time.Hour)
+
+	// Нейтральное действие защиту не снимает
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(protected.ID), "action": "emote", "emote": "wave"})
+	if !isProtected(protected, time.Now()) {
+		t.Fatal("emote снял защиту")
+	}
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(protected.ID), "action": "push"})
+	if isProtected(protected, time.Now()) {
+		t.Fatal("push не снял защиту")
+	}
+	if !isKnockedBack(enemy) {
+		t.Fatal("push защищённого игрока не сработал")
+	}
+	// Теперь игрока можно отбросить в ответ
+	enemy.X, enemy.Y = 450, 400
+	applyPush(enemy)
+	if !isKnockedBack(protected) {
+		t.Fatal("напавший игрок остался неуязвимым")
+	}
+}
+
+func TestAddressCannotControlOtherPlayer(t *testing.T) {
+	resetGame(t)
+	victim, _ := addTestPlayer(t, 400, 400)
+	_, attacker := addTestPlayer(t, 700, 700)
+
+	// Привязка id -> адрес: с чужого адреса игроком не управлять
+	handleUDPMessage(attacker.addr(), map[string]interface{}{"id": float64(victim.ID), "x": 410.0, "y": 400.0})
+	if victim.X != 400 {
+		t.Fatalf("чужой адрес переместил игрока в x=%v", victim.X)
+	}
+	// Даже подменённый id -> адрес не помогает без обратной привязки
+	clientAddrs[victim.ID] = attacker.addr()
+	if ownsPlayer(attacker.addr(), victim.ID) {
+		t.Fatal("адрес управляет игроком, не зарегистрированным с него")
+	}
+}
+
+func TestAddressRegistersOnePlayer(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 400, 400)
+	client.recv("joined")
+
+	// Привязка адрес -> id: второй игрок с того же адреса не регистрируется
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "name": "second"})
+	if reply := client.recvError("already_joined"); msgFloat(t, reply, "id") != float64(player.ID) {
+		t.Fatalf("в отказе указан игрок %v, ожидался %d", reply["id"], player.ID)
+	}
+	if len(players) != 1 {
+		t.Fatalf("игроков %d, ожидался 1", len(players))
+	}
+
+	config.AllowMultiplePerAddress = true
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "name": "second"})
+	second := client.recv("joined")
+	secondID := int(msgFloat(t, second, "id"))
+	if !ownsPlayer(client.addr(), player.ID) || !ownsPlayer(client.addr(), secondID) {
+		t.Fatalf("адрес не управляет обоими своими игроками: %v", addrPlayers[client.addr().String()])
+	}
+}
+
+func TestUnreachablePlayerRemoved(t *testing.T) {
+	resetGame(t)
+	config.MaxWriteFailures = 3
+	player, _ := addTestPlayer(t, 400, 400)
+	_, other := addTestPlayer(t, 700, 700)
+	// IPv4-сокет не может отправить на IPv6-адрес: каждая запись падает
+	clientAddrs[player.ID] = &net.UDPAddr{IP: net.IPv6loopback, Port: 9}
+
+	now := time.Now()
+	for i := 1; i < config.MaxWriteFailures; i++ {
+		gameTick(now, 10*time.Millisecond, true)
+		if _, ok := players[player.ID]; !ok {
+			t.Fatalf("игрок удалён после %d неудачных отправок", i)
+		}
+	}
+	gameTick(now, 10*time.Millisecond, true)
+	if _, ok := players[player.ID]; ok {
+		t.Fatalf("игрок не удалён после %d неудачных отправок", config.MaxWriteFailures)
+	}
+	if _, ok := writeFailures[player.ID]; ok {
+		t.Fatal("счётчик неудач удалённого игрока остался")
+	}
+	leave, ok := other.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "leave" })
+	if !ok || leave["reason"] != "unreachable" {
+		t.Fatalf("остальные игроки не узнали об уходе: %v", leave)
+	}
+}
+
+func TestOversizedStateDoesNotDisconnect(t *testing.T) {
+	resetGame(t)
+	config.MaxWriteFailures = 3
+	player, _ := addTestPlayer(t, 400, 400)
+	// Состояние с таким именем не помещается в датаграмму: ошибка размера
+	// не говорит о недоступности адреса
+	player.Name = strings.Repeat("x", maxDatagramSize)
+
+	for i := 0; i < 2*config.MaxWriteFailures; i++ {
+		gameTick(time.Now(), 10*time.Millisecond, true)
+	}
+	if _, ok := players[player.ID]; !ok {
+		t.Fatal("игрок удалён из-за слишком большого состояния")
+	}
+	if writeFailures[player.ID] != 0 {
+		t.Fatalf("ошибка размера учтена как недоступность: %d", writeFailures[player.ID])
+	}
+}
+
+func TestFullServerStateFitsDatagram(t *testing.T) {
+	resetGame(t)
+	config.CooldownsInState = cooldownsAll
+	addBotPlayers(maxStatePlayers)
+	now := time.Now()
+	for _, player := range players {
+		player.Name = fmt.Sprintf("player-%d-with-a-long-name", player.ID)
+		player.LastPushTime = now
+		player.LastPullTime = now
+		grantBuff(player, "speed", 1.5, time.Minute, now)
+	}
+
+	state := currentGameState(true)
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > maxDatagramSize {
+		t.Fatalf("состояние %d игроков занимает %d байт, больше датаграммы", maxStatePlayers, len(data))
+	}
+
+	// Больше игроков сервер не принимает, даже если MaxPlayers не задан
+	client := newTestClient(t)
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion)})
+	client.recvError("server_full")
+}
+
+func TestLeaveRemovesPlayerImmediately(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 100, 700)
+	other, otherClient := addTestPlayer(t, 700, 700)
+	cp := &capturePoints[0]
+	ownPoint(cp, player, scoreInterval)
+
+	// Чужой адрес не может выгнать игрока
+	handleUDPMessage(otherClient.addr(), map[string]interface{}{"id": float64(player.ID), "type": "leave"})
+	if _, ok := players[player.ID]; !ok {
+		t.Fatal("игрок удалён сообщением с чужого адреса")
+	}
+
+	handleUDPMessage(client.addr(), map[string]interface{}{"id": float64(player.ID), "type": "leave"})
+	if _, ok := players[player.ID]; ok {
+		t.Fatal("игрок не удалён по своему сообщению о выходе")
+	}
+	if _, ok := clientAddrs[player.ID]; ok {
+		t.Fatal("адрес ушедшего игрока остался")
+	}
+	if cp.IsCaptured {
+		t.Fatal("точка ушедшего игрока не освобождена")
+	}
+	leave, ok := otherClient.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "leave" })
+	if !ok || msgFloat(t, leave, "id") != float64(player.ID) || leave["reason"] != "left" {
+		t.Fatalf("событие выхода: %v", leave)
+	}
+	if _, ok := players[other.ID]; !ok {
+		t.Fatal("удалён не тот игрок")
+	}
+}
+
+func TestJoinWithStartingPoints(t *testing.T) {
+	resetGame(t)
+	config.StartingPoints = 10
+	client := newTestClient(t)
+
+	handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion)})
+	joined := client.recv("joined")
+	id := int(msgFloat(t, joined, "id"))
+	if players[id].Points != 10 {
+		t.Fatalf("очков при подключении %d, ожидалось 10", players[id].Points)
+	}
+	state, _ := joined["state"].(map[string]interface{})
+	list, _ := state["players"].([]interface{})
+	if len(list) != 1 || msgFloat(t, list[0].(map[string]interface{}), "points") != 10 {
+		t.Fatalf("игроки в начальном состоянии: %v", list)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// stateEncoder — переиспользуемый буфер вместе с привязанным к нему json.Encoder
+type stateEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// Наибольший принимаемый размер пакета
+const maxPacketSize = 2048
+
+// Состояние игры уходит одной UDP-датаграммой, поэтому число игроков
+// ограничено её размером. Размер игрока в JSON оценён с запасом на имя и
+// перезарядки, stateOverhead — запас на точки захвата и поля состояния
+const (
+	maxDatagramSize = 65507
+	playerStateSize = 512
+	stateOverhead   = 8192
+	maxStatePlayers = (maxDatagramSize - stateOverhead) / playerStateSize
+)
+
+var (
+	// Пул буферов для чтения входящих пакетов. Буфер на байт длиннее
+	// maxPacketSize, чтобы отличать пакет предельного размера от обрезанного
+	packetPool = sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, maxPacketSize+1)
+			return &b
+		},
+	}
+
+	// Пул кодировщиков состояния игры, чтобы не выделять буферы на каждый тик
+	encoderPool = sync.Pool{
+		New: func() interface{} {
+			e := &stateEncoder{}
+			e.enc = json.NewEncoder(&e.buf)
+			return e
+		},
+	}
+
+	// Пул срезов игроков для снимков состояния
+	playersPool = sync.Pool{
+		New: func() interface{} {
+			s := make([]Player, 0, 16)
+			return &s
+		},
+	}
+)
+
+// encode сериализует v во внутренний буфер. Возвращённый срез действителен до
+// следующего вызова encode или возврата кодировщика в пул, поэтому возвращать
+// кодировщик можно только после завершения всех WriteToUDP с этими данными
+func (e *stateEncoder) encode(v interface{}) ([]byte, error) {
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// Encoder добавляет перевод строки, клиентам он не нужен
+	data := e.buf.Bytes()
+	return data[:len(data)-1], nil
+}
+
+// releasePlayersState возвращает срез, полученный из getPlayersState, в пул
+func releasePlayersState(s []Player) {
+	s = s[:0]
+	playersPool.Put(&s)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// addBotPlayers добавляет n игроков без сетевых адресов: состояние для них
+// собирается и сериализуется, но никуда не отправляется
+func addBotPlayers(n int) {
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		nextPlayerID++
+		players[nextPlayerID] = &Player{
+			ID:           nextPlayerID,
+			X:            float64(50 + i%18*50),
+			Y:            float64(50 + i/18%14*50),
+			Name:         fmt.Sprintf("bot%d", nextPlayerID),
+			Color:        playerColor(nextPlayerID),
+			LastStand:    1,
+			Ping:         -1,
+			HP:           config.MaxHP,
+			Attrs:        defaultSkin,
+			LastSeen:     now,
+			LastActivity: now,
+			LastMoveTime: now,
+		}
+	}
+}
+
+func TestPooledEncoderMatchesMarshal(t *testing.T) {
+	resetGame(t)
+	addBotPlayers(20)
+
+	state := currentGameState(true)
+	want, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := encoderPool.Get().(*stateEncoder)
+	defer encoderPool.Put(enc)
+	for i := 0; i < 2; i++ {
+		got, err := enc.encode(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("кодировщик из пула дал другой JSON:\n%s\n%s", got, want)
+		}
+	}
+	releasePlayersState(state.Players)
+}
+
+// BenchmarkStateEncoding сравнивает сериализацию снимка через пулы с
+// выделением нового среза и буфера на каждый тик. Запуск:
+//
+//	go test -run '^$' -bench StateEncoding -benchmem
+func BenchmarkStateEncoding(b *testing.B) {
+	resetGame(b)
+	addBotPlayers(50)
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			state := currentGameState(false)
+			enc := encoderPool.Get().(*stateEncoder)
+			if _, err := enc.encode(state); err != nil {
+				b.Fatal(err)
+			}
+			releasePlayersState(state.Players)
+			encoderPool.Put(enc)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			state := GameState{CapturePoints: getCapturePointsState(), Epoch: serverEpoch}
+			for _, player := range players {
+				state.Players = append(state.Players, *player)
+			}
+			if _, err := json.Marshal(state); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestPacketBuffersNotShared читает поток пакетов от нескольких клиентов в
+// буферы из пула и проверяет их в нескольких обработчиках одновременно.
+// Обработчик не должен увидеть байты другого пакета: буфер возвращается в
+// пул только после обработки. Запускать с -race
+func TestPacketBuffersNotShared(t *testing.T) {
+	resetGame(t)
+	const clients, perClient, workers = 4, 300, 4
+
+	packets := make(chan packet, packetQueueSize)
+	readDone := make(chan struct{})
+	go func() {
+		readPackets(packets)
+		close(readDone)
+	}()
+
+	var checked atomic.Int64
+	var handlers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			for p := range packets {
+				data := (*p.buf)[:p.n]
+				var client, seq int
+				prefix, body, _ := bytes.Cut(data, []byte("|"))
+				// Задержка расширяет окно, в котором чтение могло бы
+				// перезаписать буфер, если бы он был общим
+				time.Sleep(10 * time.Microsecond)
+				if _, err := fmt.Sscanf(string(prefix), "%d:%d", &client, &seq); err != nil ||
+					!bytes.Equal(body, packetBody(client, seq)) {
+					t.Errorf("повреждённый пакет: %q", data)
+				}
+				packetPool.Put(p.buf)
+				checked.Add(1)
+			}
+		}()
+	}
+
+	var senders sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		client := newTestClient(t)
+		senders.Add(1)
+		go func(c int) {
+			defer senders.Done()
+			for seq := 0; seq < perClient; seq++ {
+				payload := append([]byte(fmt.Sprintf("%d:%d|", c, seq)), packetBody(c, seq)...)
+				client.conn.WriteToUDP(payload, conn.LocalAddr().(*net.UDPAddr))
+			}
+		}(c)
+	}
+	senders.Wait()
+	time.Sleep(100 * time.Millisecond)
+	conn.Close()
+	<-readDone
+	close(packets)
+	handlers.Wait()
+
+	if checked.Load() == 0 {
+		t.Fatal("ни один пакет не обработан")
+	}
+}
+
+// packetBody возвращает тело тестового пакета, разное по длине и содержимому
+// у каждого клиента и номера
+func packetBody(client, seq int) []byte {
+	return bytes.Repeat([]byte{byte('a' + (client*7+seq)%26)}, 100+(client*31+seq)%400)
+}
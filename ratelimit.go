@@ -0,0 +1,49 @@
+package main
+
+import "time"
+
+// tokenBucket — ограничитель частоты сообщений: жетоны пополняются со
+// скоростью rate в секунду, но не больше burst, каждое сообщение тратит жетон
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow тратит жетон, если он есть. Нулевая или отрицательная частота
+// отключает ограничение. Вызывается под mutex
+func (b *tokenBucket) allow(rate, burst float64, now time.Time) bool {
+	if rate <= 0 {
+		return true
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*rate, burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimit — частота и запас сообщений одного вида в конфигурации
+type RateLimit struct {
+	Rate  float64 `json:"rate"`  // Сообщений в секунду (0 — без ограничения)
+	Burst float64 `json:"burst"` // Сколько сообщений можно прислать разом
+}
+
+// allowMessage проверяет частоту сообщений игрока по виду сообщения,
+// вызывается под mutex
+func allowMessage(player *Player, bucket *tokenBucket, limit RateLimit, kind string, now time.Time) bool {
+	if bucket.allow(limit.Rate, limit.Burst, now) {
+		return true
+	}
+	logDebug("Игрок %d превысил частоту сообщений (%s), сообщение отброшено", player.ID, kind)
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"time"
+)
+
+const (
+	reliableRetryInterval = 250 * time.Millisecond // Повтор неподтверждённого сообщения
+	reliableMaxAttempts   = 8                      // После стольких отправок сообщение отбрасывается
+)
+
+// pendingMessage — надёжное сообщение, ожидающее подтверждения клиентом
+type pendingMessage struct {
+	data     []byte
+	sentAt   time.Time
+	attempts int
+}
+
+var (
+	reliableQueues  = make(map[int]map[int]*pendingMessage) // Неподтверждённые сообщения по игроку и номеру
+	nextReliableSeq = make(map[int]int)                     // Последний выданный номер надёжного сообщения игрока
+)
+
+// sendReliable отправляет игроку сообщение с номером "rseq" и повторяет его,
+// пока клиент не пришлёт {"type": "ack", "rseq": N}. Вызывается под mutex
+func sendReliable(playerID int, msg map[string]interface{}) {
+	addr, ok := clientAddrs[playerID]
+	if !ok {
+		return
+	}
+
+	nextReliableSeq[playerID]++
+	seq := nextReliableSeq[playerID]
+	msg["rseq"] = seq
+	data, err := json.Marshal(msg)
+	if err != nil {
+		recordMarshalError("сообщения", err)
+		return
+	}
+
+	if reliableQueues[playerID] == nil {
+		reliableQueues[playerID] = make(map[int]*pendingMessage)
+	}
+	reliableQueues[playerID][seq] = &pendingMessage{data: data, sentAt: clock(), attempts: 1}
+	writeUDP(addr, data)
+}
+
+// broadcastReliable надёжно отправляет сообщение всем игрокам, кроме exceptID.
+// Каждый получатель получает свою копию со своим "rseq". Вызывается под mutex
+func broadcastReliable(msg map[string]interface{}, exceptID int) {
+	for id := range players {
+		if id != exceptID {
+			sendReliable(id, maps.Clone(msg))
+		}
+	}
+}
+
+// handleAck снимает подтверждённое сообщение с повтора, вызывается под mutex
+func handleAck(player *Player, msg map[string]interface{}) {
+	seq, ok := msg["rseq"].(float64)
+	if !ok {
+		return
+	}
+	delete(reliableQueues[player.ID], int(seq))
+}
+
+// retransmitReliable повторяет сообщения, подтверждение которых не пришло
+// вовремя, вызывается под mutex из игрового тика
+func retransmitReliable(now time.Time) {
+	for playerID, queue := range reliableQueues {
+		addr, ok := clientAddrs[playerID]
+		if !ok {
+			purgeReliable(playerID)
+			continue
+		}
+		for seq, pending := range queue {
+			if now.Sub(pending.sentAt) < reliableRetryInterval {
+				continue
+			}
+			if pending.attempts >= reliableMaxAttempts {
+				delete(queue, seq)
+				logDebug("Надёжное сообщение %d игроку %d не подтверждено и отброшено", seq, playerID)
+				continue
+			}
+			pending.attempts++
+			pending.sentAt = now
+			writeUDP(addr, pending.data)
+		}
+	}
+}
+
+// purgeReliable забывает неподтверждённые сообщения отключившегося игрока,
+// чтобы они не отправлялись на мёртвый адрес. Вызывается под mutex
+func purgeReliable(playerID int) {
+	delete(reliableQueues, playerID)
+	delete(nextReliableSeq, playerID)
+}
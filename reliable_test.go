@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemovedPlayerReliableQueueCleared(t *testing.T) {
+	resetGame(t)
+	gone, goneClient := addTestPlayer(t, 100, 100)
+	addTestPlayer(t, 700, 700)
+
+	// О подключении второго игрока первый тоже узнаёт надёжным сообщением
+	sendReliable(gone.ID, map[string]interface{}{"type": "event", "event": "test"})
+	if len(reliableQueues[gone.ID]) != 2 {
+		t.Fatalf("в очереди %d сообщений, ожидалось два", len(reliableQueues[gone.ID]))
+	}
+	// Уже отправленные сообщения вычитываются, чтобы не принять их за повторы
+	goneClient.next(50*time.Millisecond, func(map[string]interface{}) bool { return false })
+
+	removePlayer(gone.ID, "left")
+	if _, ok := reliableQueues[gone.ID]; ok {
+		t.Fatal("очередь надёжных сообщений ушедшего игрока не очищена")
+	}
+	// Повторы по времени больше не уходят на адрес ушедшего игрока
+	retransmitReliable(time.Now().Add(time.Hour))
+	goneClient.expectNone(100*time.Millisecond, hasType("event"))
+}
+
+func TestUnackedReliableRetransmitted(t *testing.T) {
+	resetGame(t)
+	player, client := addTestPlayer(t, 100, 100)
+	sendReliable(player.ID, map[string]interface{}{"type": "event", "event": "test"})
+	isTest := func(m map[string]interface{}) bool { return m["event"] == "test" }
+	first, _ := client.next(testTimeout, isTest)
+
+	retransmitReliable(time.Now().Add(reliableRetryInterval))
+	if again, _ := client.next(testTimeout, isTest); again["rseq"] != first["rseq"] {
+		t.Fatalf("повтор с другим номером: %v и %v", first["rseq"], again["rseq"])
+	}
+	handleAck(player, map[string]interface{}{"rseq": first["rseq"]})
+	retransmitReliable(time.Now().Add(time.Hour))
+	client.expectNone(100*time.Millisecond, isTest)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	shutdownOnce sync.Once
+
+	// Закрывается при остановке сервера, по нему завершаются игровые циклы
+	serverDone = make(chan struct{})
+	// Игровые циклы, запущенные serve
+	serverLoops sync.WaitGroup
+)
+
+// watchShutdownSignal корректно останавливает сервер по SIGINT или SIGTERM
+func watchShutdownSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	shutdown()
+}
+
+// Сколько shutdown ждёт завершения запросов к серверу метрик
+const metricsShutdownTimeout = 2 * time.Second
+
+// shutdown предупреждает игроков об остановке, дописывает журнал аудита,
+// останавливает игровые циклы, сервер метрик и закрывает сокет, после чего
+// serve дожидается записи таблицы лидеров и возвращает управление.
+// Повторные вызовы ничего не делают
+func shutdown() {
+	shutdownOnce.Do(func() {
+		mutex.Lock()
+		log.Println("Сервер останавливается")
+		for id := range players {
+			sendToPlayer(id, map[string]interface{}{"type": "shutdown"})
+		}
+		stopAudit()
+		close(serverDone)
+		mutex.Unlock()
+
+		conn.Close()
+		if metricsServer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+			defer cancel()
+			if err := metricsServer.Shutdown(ctx); err != nil {
+				log.Println("Ошибка остановки сервера метрик:", err)
+			}
+		}
+	})
+}
+
+// waitTick ждёт d между итерациями игрового цикла. Возвращает false, если
+// сервер останавливается и цикл должен завершиться
+func waitTick(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-serverDone:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+// SkinAttributes — игровые параметры скина. Нулевые и отрицательные
+// множители считаются равными 1
+type SkinAttributes struct {
+	Mass     float64 `json:"mass"`     // Масса: отброс делится на неё
+	Speed    float64 `json:"speed"`    // Множитель максимальной скорости
+	Cooldown float64 `json:"cooldown"` // Множитель перезарядки действий
+}
+
+// defaultSkin — параметры скина без настроек
+var defaultSkin = SkinAttributes{Mass: 1, Speed: 1, Cooldown: 1}
+
+// skinAttributes возвращает параметры скина по config.Skins. Неизвестный скин
+// получает параметры по умолчанию
+func skinAttributes(skin string) SkinAttributes {
+	attrs, ok := config.Skins[skin]
+	if !ok {
+		return defaultSkin
+	}
+	if attrs.Mass <= 0 {
+		attrs.Mass = 1
+	}
+	if attrs.Speed <= 0 {
+		attrs.Speed = 1
+	}
+	if attrs.Cooldown <= 0 {
+		attrs.Cooldown = 1
+	}
+	return attrs
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestHeavySkinPushedLess(t *testing.T) {
+	displacement := func(skin string) (float64, SkinAttributes) {
+		resetGame(t)
+		config.Skins = map[string]SkinAttributes{"heavy": {Mass: 2}}
+		actor, _ := addTestPlayer(t, 400, 400)
+		client := newTestClient(t)
+		handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "skin": skin})
+		target := players[nextPlayerID]
+		target.X, target.Y = 450, 400
+		target.ProtectedUntil = actor.ProtectedUntil
+
+		applyPush(actor)
+		stepKnockback(t, target)
+		return target.X - 450, target.Attrs
+	}
+
+	normal, normalAttrs := displacement("")
+	heavy, heavyAttrs := displacement("heavy")
+	unknown, unknownAttrs := displacement("no-such-skin")
+	if heavyAttrs.Mass != 2 || normalAttrs != defaultSkin || unknownAttrs != defaultSkin {
+		t.Fatalf("параметры скинов: %+v, %+v, %+v", normalAttrs, heavyAttrs, unknownAttrs)
+	}
+	if heavy <= 0 || heavy >= normal*0.6 || unknown != normal {
+		t.Fatalf("отброс: обычный %v, тяжёлый %v, неизвестный скин %v", normal, heavy, unknown)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"net"
+	"time"
+)
+
+// Spectator — клиент, который получает состояние игры, но не управляет игроком
+type Spectator struct {
+	Addr     *net.UDPAddr
+	LastSeen time.Time // Время последнего сообщения от зрителя
+}
+
+// Зрители по адресу клиента, не учитываются в players и MaxPlayers
+var spectators = make(map[string]*Spectator)
+
+// joinSpectator добавляет зрителя, вызывается под mutex
+func joinSpectator(addr *net.UDPAddr) {
+	key := addr.String()
+	if _, ok := spectators[key]; !ok {
+		if config.MaxSpectators > 0 && len(spectators) >= config.MaxSpectators {
+			sendUDPMessage(addr, map[string]interface{}{"error": "spectators_full"})
+			return
+		}
+		log.Printf("Зритель %s подключился", addr)
+		trackConnection(addr)
+	}
+	spectators[key] = &Spectator{Addr: addr, LastSeen: clock()}
+
+	sendUDPMessage(addr, map[string]interface{}{
+		"type":        "joined",
+		"spectator":   true,
+		"serverEpoch": serverEpoch,
+		"map":         mapInfo(),
+		"state":       currentGameState(true),
+	})
+}
+
+// touchSpectator обновляет время активности зрителя. Возвращает false, если
+// адрес не принадлежит зрителю. Вызывается под mutex
+func touchSpectator(addr *net.UDPAddr) bool {
+	spectator, ok := spectators[addr.String()]
+	if ok {
+		spectator.LastSeen = clock()
+	}
+	return ok
+}
+
+// reapSpectators удаляет зрителей, от которых давно не было сообщений,
+// вызывается под mutex
+func reapSpectators(now time.Time) {
+	timeout := time.Duration(config.PlayerTimeout)
+	if timeout <= 0 {
+		return
+	}
+	for key, spectator := range spectators {
+		if now.Sub(spectator.LastSeen) > timeout {
+			delete(spectators, key)
+			dropWaiting(key)
+			if len(addrPlayers[key]) == 0 {
+				untrackConnection(key)
+				closeDTLSSession(key)
+			}
+			log.Printf("Зритель %s удалён (timeout)", key)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpectatorReceivesStateButCannotControl(t *testing.T) {
+	resetGame(t)
+	player, _ := addTestPlayer(t, 400, 400)
+	target, _ := addTestPlayer(t, 450, 400)
+	spectator := newTestClient(t)
+
+	handleJoin(spectator.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "spectator": true})
+	if joined := spectator.recv("joined"); joined["spectator"] != true {
+		t.Fatalf("ответ зрителю %v", joined)
+	}
+	if len(players) != 2 {
+		t.Fatalf("зритель учтён как игрок: %d игроков", len(players))
+	}
+
+	gameTick(time.Now(), 10*time.Millisecond, false)
+	spectator.recvState()
+
+	// Сообщения зрителя от имени игрока игнорируются
+	handleUDPMessage(spectator.addr(), map[string]interface{}{"id": float64(player.ID), "x": 300.0, "y": 300.0})
+	handleUDPMessage(spectator.addr(), map[string]interface{}{"id": float64(player.ID), "action": "push"})
+	if player.X != 400 || player.Y != 400 || isKnockedBack(target) || !player.LastPushTime.IsZero() {
+		t.Fatal("сообщение зрителя изменило состояние игры")
+	}
+}
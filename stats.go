@@ -0,0 +1,59 @@
+package main
+
+import "time"
+
+// PlayerStats — статистика игрока за текущий матч
+type PlayerStats struct {
+	Captures     int     `json:"captures"`     // Завершённые захваты точек
+	Assists      int     `json:"assists"`      // Помощь в захватах, завершённых другими игроками
+	PushesLanded int     `json:"pushesLanded"` // Попадания "push"
+	PullsLanded  int     `json:"pullsLanded"`  // Попадания "pull"
+	Distance     float64 `json:"distance"`     // Пройденное расстояние
+	TimeOnPoint  float64 `json:"timeOnPoint"`  // Время в зонах захвата, секунды
+}
+
+// Время последнего учёта нахождения игроков в зонах
+var lastTimeOnPointUpdate time.Time
+
+// updateTimeOnPoint добавляет игрокам, стоящим в зонах захвата, время с
+// прошлого вызова. Игрок в нескольких пересекающихся зонах учитывается один раз.
+// Вызывается под mutex после обновления Occupants
+func updateTimeOnPoint(now time.Time) {
+	if !lastTimeOnPointUpdate.IsZero() {
+		elapsed := now.Sub(lastTimeOnPointUpdate).Seconds()
+		counted := make(map[int]bool)
+		for i := range capturePoints {
+			for id := range capturePoints[i].Occupants {
+				if player, ok := players[id]; ok && !counted[id] {
+					counted[id] = true
+					player.Stats.TimeOnPoint += elapsed
+				}
+			}
+		}
+	}
+	lastTimeOnPointUpdate = now
+}
+
+// resetStats обнуляет статистику всех игроков перед новым матчем, вызывается под mutex
+func resetStats() {
+	for _, player := range players {
+		player.Stats = PlayerStats{}
+	}
+	lastTimeOnPointUpdate = time.Time{}
+}
+
+// matchSummary возвращает итоговую статистику игроков в порядке таблицы,
+// вызывается под mutex
+func matchSummary() []map[string]interface{} {
+	summary := standings()
+	for _, entry := range summary {
+		player := players[entry["id"].(int)]
+		entry["captures"] = player.Stats.Captures
+		entry["assists"] = player.Stats.Assists
+		entry["pushesLanded"] = player.Stats.PushesLanded
+		entry["pullsLanded"] = player.Stats.PullsLanded
+		entry["distance"] = player.Stats.Distance
+		entry["timeOnPoint"] = player.Stats.TimeOnPoint
+	}
+	return summary
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummaryCountsCaptures(t *testing.T) {
+	resetGame(t)
+	player, _ := addTestPlayer(t, 100, 700)
+	addTestPlayer(t, 900, 100)
+
+	completeCapture(&capturePoints[0], player)
+	completeCapture(&capturePoints[1], player)
+	if !capturePoints[0].IsCaptured || !capturePoints[1].IsCaptured {
+		t.Fatal("точки не захвачены")
+	}
+
+	for _, entry := range matchSummary() {
+		if entry["id"] == player.ID && entry["captures"] != 2 {
+			t.Fatalf("в итогах %v", entry)
+		}
+		if entry["id"] != player.ID && entry["captures"] != 0 {
+			t.Fatalf("захваты у другого игрока: %v", entry)
+		}
+	}
+}
+
+func TestPushedOutContributorGetsAssist(t *testing.T) {
+	resetGame(t)
+	cp := &capturePoints[0]
+	helper, _ := addTestPlayer(t, cp.X, cp.Y)
+	capturer, _ := addTestPlayer(t, 900, 100)
+	stale, _ := addTestPlayer(t, 900, 700)
+
+	// Помощник простоял в зоне большую часть захвата, но к его завершению
+	// оказался снаружи. Участник, ушедший дольше AssistWindow назад, забыт
+	updateCapturePoints()
+	cp.Contributors[stale.ID] = time.Now().Add(-time.Duration(config.AssistWindow) - time.Second)
+	helper.X, helper.Y = 900, 400
+	completeCapture(cp, capturer)
+	if !cp.IsCaptured || cp.CapturingPlayer != capturer.ID {
+		t.Fatal("точка не захвачена")
+	}
+
+	if helper.Stats.Assists != 1 || capturer.Stats.Assists != 0 || stale.Stats.Assists != 0 {
+		t.Fatalf("помощь: помощник %d, захватчик %d, давний участник %d",
+			helper.Stats.Assists, capturer.Stats.Assists, stale.Stats.Assists)
+	}
+	for _, entry := range matchSummary() {
+		if entry["id"] == helper.ID && entry["assists"] != 1 {
+			t.Fatalf("в итогах %v", entry)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// teamsEnabled проверяет, играют ли игроки командами
+func teamsEnabled() bool {
+	return config.Teams > 1
+}
+
+// assignTeam выбирает команду для нового игрока: ту, в которой меньше
+// игроков, при равенстве — с меньшим номером. Вызывается под mutex
+func assignTeam() int {
+	if !teamsEnabled() {
+		return 0
+	}
+	sizes := teamSizes()
+	team := 1
+	for t := 2; t <= config.Teams; t++ {
+		if sizes[t] < sizes[team] {
+			team = t
+		}
+	}
+	return team
+}
+
+// teamSizes считает игроков в каждой команде, вызывается под mutex
+func teamSizes() map[int]int {
+	sizes := make(map[int]int, config.Teams)
+	for _, player := range players {
+		sizes[player.Team]++
+	}
+	return sizes
+}
+
+// rebalanceTeams выравнивает команды, если разница между самой большой и самой
+// маленькой превысила TeamImbalance: из большей команды в меньшую переводится
+// игрок, подключившийся последним. Вызывается под mutex
+func rebalanceTeams() {
+	if !teamsEnabled() || config.TeamImbalance <= 0 {
+		return
+	}
+	for {
+		sizes := teamSizes()
+		largest, smallest := 1, 1
+		for t := 2; t <= config.Teams; t++ {
+			if sizes[t] > sizes[largest] {
+				largest = t
+			}
+			if sizes[t] < sizes[smallest] {
+				smallest = t
+			}
+		}
+		if sizes[largest]-sizes[smallest] <= config.TeamImbalance {
+			return
+		}
+
+		var newest *Player
+		for _, player := range players {
+			if player.Team == largest && (newest == nil || player.ID > newest.ID) {
+				newest = player
+			}
+		}
+		log.Printf("Команды неравны (%d против %d), перебалансировка", sizes[largest], sizes[smallest])
+		setPlayerTeam(newest, smallest)
+	}
+}
+
+// setPlayerTeam переводит игрока в другую команду. Точки, захваченные им для
+// прежней команды, становятся нейтральными: иначе новая команда получала бы
+// очки за чужой захват. Незавершённый захват тоже начинается заново.
+// Вызывается под mutex
+func setPlayerTeam(player *Player, team int) {
+	if player.Team == team {
+		return
+	}
+	previous := player.Team
+	player.Team = team
+
+	for i := range capturePoints {
+		cp := &capturePoints[i]
+		if cp.IsCaptured && cp.CapturingPlayer == player.ID && cp.OwnerTeam != team {
+			neutralizePoint(cp, "team_change")
+		}
+		if cp.CurrentCapturingPlayer == player.ID {
+			cp.CurrentCapturingPlayer = 0
+			cp.EnterTime = time.Time{}
+		}
+	}
+
+	broadcastEvent(map[string]interface{}{
+		"type":     "team",
+		"id":       player.ID,
+		"team":     team,
+		"previous": previous,
+	})
+	log.Printf("Игрок %d переведён из команды %d в команду %d", player.ID, previous, team)
+}
+
+// updateLastStand пересчитывает множитель "последнего рубежа": команда, против
+// которой активных (не AFK) игроков какой-либо другой команды в LastStandRatio
+// раз больше, получает очки с множителем LastStandMultiplier. Вызывается под mutex
+func updateLastStand(now time.Time) {
+	active := make(map[int]int, config.Teams)
+	for _, player := range players {
+		if !isAFK(player, now) {
+			active[player.Team]++
+		}
+	}
+	strongest := 0
+	for team := 1; team <= config.Teams; team++ {
+		strongest = max(strongest, active[team])
+	}
+
+	for _, player := range players {
+		player.LastStand = 1
+		own := active[player.Team]
+		if !teamsEnabled() || config.LastStandMultiplier <= 1 || config.LastStandRatio <= 0 || own == 0 {
+			continue
+		}
+		if float64(strongest) >= config.LastStandRatio*float64(own) {
+			player.LastStand = config.LastStandMultiplier
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTeamChangeNeutralizesPoint(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	owner, client := addTestPlayer(t, 100, 700)
+	owner.Team = 1
+	cp := &capturePoints[1]
+	ownPoint(cp, owner, scoreInterval)
+
+	setPlayerTeam(owner, 2)
+	if cp.IsCaptured || cp.CapturingPlayer != 0 || cp.OwnerTeam != 0 {
+		t.Fatalf("точка после смены команды владельца: %+v", *cp)
+	}
+	msg, ok := client.next(testTimeout, func(m map[string]interface{}) bool { return m["event"] == "neutralized" })
+	if !ok || msg["reason"] != "team_change" {
+		t.Fatalf("событие нейтрализации: %v", msg)
+	}
+	updateCapturePoints()
+	if owner.Points != 0 {
+		t.Fatalf("новая команда получила %d очков за чужой захват", owner.Points)
+	}
+
+	// Смена команды в обход setPlayerTeam тоже не приносит очков
+	ownPoint(cp, owner, scoreInterval)
+	owner.Team = 1
+	updateCapturePoints()
+	if cp.IsCaptured || owner.Points != 0 {
+		t.Fatalf("точка захвачена=%v, очков %d", cp.IsCaptured, owner.Points)
+	}
+}
+
+func TestJoinsBalanceTeams(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	for _, want := range []map[int]int{{1: 1}, {1: 1, 2: 1}, {1: 2, 2: 1}, {1: 2, 2: 2}} {
+		addTestPlayer(t, 400, 400)
+		sizes := teamSizes()
+		if sizes[1] != want[1] || sizes[2] != want[2] {
+			t.Fatalf("после %d подключений команды %v, ожидалось %v", len(players), sizes, want)
+		}
+	}
+}
+
+func TestRebalanceMovesNewestPlayer(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	config.TeamImbalance = 1
+	var team1, team2 []*Player
+	for i := 0; i < 4; i++ {
+		player, _ := addTestPlayer(t, 400, 400)
+		if player.Team == 1 {
+			team1 = append(team1, player)
+		} else {
+			team2 = append(team2, player)
+		}
+	}
+	// Уход двух игроков второй команды делает разницу больше допустимой
+	for _, player := range team2 {
+		removePlayer(player.ID, "test")
+	}
+	if sizes := teamSizes(); sizes[1] != 1 || sizes[2] != 1 {
+		t.Fatalf("после перебалансировки команды %v", sizes)
+	}
+	if newest := team1[len(team1)-1]; newest.Team != 2 {
+		t.Fatalf("переведён не последний подключившийся игрок: %+v", team1)
+	}
+}
+
+func TestPlayersSpawnInTeamRegion(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	config.TeamSpawns = map[int][]Point{
+		1: {{X: 100, Y: 100}, {X: 150, Y: 100}},
+		2: {{X: 900, Y: 700}, {X: 850, Y: 700}},
+	}
+	inRegion := func(player *Player, team int) bool {
+		for _, p := range config.TeamSpawns[team] {
+			if player.X == p.X && player.Y == p.Y {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < 20; i++ {
+		addPlayer(newTestClient(t).addr(), joinRequest{name: "p", encoding: encodingJSON})
+		player := players[nextPlayerID]
+		other := 3 - player.Team
+		if !inRegion(player, player.Team) || inRegion(player, other) {
+			t.Fatalf("игрок команды %d появился в (%v, %v)", player.Team, player.X, player.Y)
+		}
+	}
+
+	// Без команд игроки появляются на общих точках
+	config.Teams = 0
+	addPlayer(newTestClient(t).addr(), joinRequest{name: "p", encoding: encodingJSON})
+	player := players[nextPlayerID]
+	if spawn := config.SpawnPoints; !slices.Contains(spawn, Point{X: player.X, Y: player.Y}) {
+		t.Fatalf("игрок без команды появился в (%v, %v), общие точки %v", player.X, player.Y, spawn)
+	}
+}
+
+func TestLastStandBoostsOutnumberedPlayer(t *testing.T) {
+	resetGame(t)
+	config.Teams = 2
+	config.LastStandMultiplier = 3
+	solo, _ := addTestPlayer(t, 100, 700)
+	first, _ := addTestPlayer(t, 500, 700)
+	second, _ := addTestPlayer(t, 550, 700)
+	solo.Team, first.Team, second.Team = 1, 2, 2
+	ownPoint(&capturePoints[0], solo, scoreInterval)
+	ownPoint(&capturePoints[1], first, scoreInterval)
+
+	updateCapturePoints()
+	if solo.Points != config.LastStandMultiplier || first.Points != 1 {
+		t.Fatalf("очки за интервал: один против двух — %d, ожидалось %d; большинство — %d, ожидалось 1",
+			solo.Points, config.LastStandMultiplier, first.Points)
+	}
+	state := getPlayersState()
+	defer releasePlayersState(state)
+	for _, p := range state {
+		if want := map[int]int{1: config.LastStandMultiplier, 2: 1}[p.Team]; p.LastStand != want {
+			t.Fatalf("в состоянии у игрока %d множитель %d, ожидался %d", p.ID, p.LastStand, want)
+		}
+	}
+
+	// Когда силы равны, множитель снимается
+	addTestPlayer(t, 150, 700)
+	players[nextPlayerID].Team = 1
+	updateLastStand(time.Now())
+	if solo.LastStand != 1 {
+		t.Fatalf("множитель %d при равных командах", solo.LastStand)
+	}
+}
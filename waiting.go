@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"net"
+	"slices"
+)
+
+// waitingJoin — клиент в очереди на свободное место
+type waitingJoin struct {
+	addr *net.UDPAddr
+	req  joinRequest
+}
+
+// Очередь ожидания в порядке подключения, защищена mutex
+var waitQueue []waitingJoin
+
+// enqueueWaiting ставит клиента в очередь ожидания. Пока место не
+// освободится, клиент получает состояние игры как зритель. Вызывается под mutex
+func enqueueWaiting(addr *net.UDPAddr, req joinRequest) {
+	key := addr.String()
+	position := slices.IndexFunc(waitQueue, func(w waitingJoin) bool { return w.addr.String() == key })
+	if position < 0 {
+		// Ожидающие получают состояние каждый тик, поэтому очередь ограничена
+		if config.MaxWaiting > 0 && len(waitQueue) >= config.MaxWaiting {
+			log.Printf("Клиент %s отклонён: сервер и очередь ожидания заполнены", addr)
+			sendUDPMessage(addr, map[string]interface{}{"error": "server_full"})
+			return
+		}
+		waitQueue = append(waitQueue, waitingJoin{addr: addr, req: req})
+		position = len(waitQueue) - 1
+		log.Printf("Клиент %s ожидает места, позиция в очереди %d", addr, position+1)
+
+		// Ограничение числа зрителей к ожидающим не применяется
+		if _, ok := spectators[key]; !ok {
+			trackConnection(addr)
+		}
+		spectators[key] = &Spectator{Addr: addr, LastSeen: clock()}
+	}
+
+	sendUDPMessage(addr, map[string]interface{}{
+		"type":     "queued",
+		"position": position + 1,
+		"map":      mapInfo(),
+		"state":    currentGameState(true),
+	})
+}
+
+// promoteWaiting переводит ожидающих клиентов в игроки, пока есть свободные
+// места, в порядке очереди. Вызывается под mutex после удаления игрока
+func promoteWaiting() {
+	for len(waitQueue) > 0 && len(players) < playerLimit() {
+		next := waitQueue[0]
+		waitQueue = waitQueue[1:]
+
+		delete(spectators, next.addr.String())
+		log.Printf("Клиент %s дождался места", next.addr)
+		addPlayer(next.addr, next.req)
+	}
+}
+
+// dropWaiting убирает клиента из очереди ожидания, вызывается под mutex
+func dropWaiting(key string) {
+	waitQueue = slices.DeleteFunc(waitQueue, func(w waitingJoin) bool { return w.addr.String() == key })
+}
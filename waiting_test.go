@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitingRoomPromotesInOrder(t *testing.T) {
+	resetGame(t)
+	config.MaxPlayers = 1
+	config.WaitingRoom = true
+	active, _ := addTestPlayer(t, 100, 100)
+
+	join := func(name string) *testClient {
+		client := newTestClient(t)
+		handleJoin(client.addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion), "name": name})
+		return client
+	}
+	first, second := join("first"), join("second")
+	if msgFloat(t, first.recv("queued"), "position") != 1 || msgFloat(t, second.recv("queued"), "position") != 2 {
+		t.Fatal("неверные позиции в очереди")
+	}
+	if len(players) != 1 {
+		t.Fatalf("игроков %d сверх лимита", len(players))
+	}
+
+	// Ожидающие тем временем получают состояние как зрители
+	gameTick(time.Now(), 10*time.Millisecond, false)
+	first.recvState()
+
+	removePlayer(active.ID, "left")
+	joined := first.recv("joined")
+	promoted := players[int(msgFloat(t, joined, "id"))]
+	if promoted == nil || promoted.Name != "first" || !ownsPlayer(first.addr(), promoted.ID) {
+		t.Fatalf("место досталось не первому в очереди: %v", joined)
+	}
+	if _, ok := spectators[first.addr().String()]; ok {
+		t.Fatal("переведённый в игроки остался зрителем")
+	}
+	if len(waitQueue) != 1 || waitQueue[0].addr.String() != second.addr().String() {
+		t.Fatalf("в очереди осталось %d", len(waitQueue))
+	}
+	second.expectNone(100*time.Millisecond, hasType("joined"))
+}
+
+func TestWaitingRoomCapped(t *testing.T) {
+	resetGame(t)
+	config.MaxPlayers = 1
+	config.WaitingRoom = true
+	config.MaxWaiting = 2
+	addTestPlayer(t, 100, 100)
+
+	clients := make([]*testClient, config.MaxWaiting+1)
+	for i := range clients {
+		clients[i] = newTestClient(t)
+		handleJoin(clients[i].addr(), map[string]interface{}{"type": "join", "protocolVersion": float64(protocolVersion)})
+	}
+	for _, client := range clients[:config.MaxWaiting] {
+		client.recv("queued")
+	}
+
+	// Сверх предела очереди клиент получает отказ и не становится зрителем
+	extra := clients[config.MaxWaiting]
+	extra.recvError("server_full")
+	if len(waitQueue) != config.MaxWaiting {
+		t.Fatalf("в очереди %d клиентов при пределе %d", len(waitQueue), config.MaxWaiting)
+	}
+	if _, ok := spectators[extra.addr().String()]; ok {
+		t.Fatal("отклонённый клиент получает состояние как зритель")
+	}
+}
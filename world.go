@@ -0,0 +1,83 @@
+package main
+
+import "fmt"
+
+// Размер мира, для которого нарисованы карта по умолчанию и встроенные карты
+const (
+	referenceWorldWidth  = 1000.0
+	referenceWorldHeight = 800.0
+)
+
+// clampToWorld возвращает ближайшую к (x, y) точку внутри мира
+func clampToWorld(x, y float64) (float64, float64) {
+	return min(max(x, 0), config.WorldWidth), min(max(y, 0), config.WorldHeight)
+}
+
+// insideWorld проверяет, лежит ли точка внутри мира
+func insideWorld(cfg Config, x, y float64) bool {
+	return x >= 0 && x <= cfg.WorldWidth && y >= 0 && y <= cfg.WorldHeight
+}
+
+// scaleMapToWorld растягивает геометрию карты, нарисованной для мира
+// referenceWorldWidth × referenceWorldHeight, до размеров мира из cfg
+func scaleMapToWorld(cfg *Config) {
+	sx := cfg.WorldWidth / referenceWorldWidth
+	sy := cfg.WorldHeight / referenceWorldHeight
+	// Радиус масштабируется по меньшей стороне, чтобы зоны не вылезали за края
+	sr := min(sx, sy)
+
+	obstacles := make([]Obstacle, len(cfg.Obstacles))
+	for i, o := range cfg.Obstacles {
+		obstacles[i] = Obstacle{X: o.X * sx, Y: o.Y * sy, Width: o.Width * sx, Height: o.Height * sy}
+	}
+	spawns := make([]Point, len(cfg.SpawnPoints))
+	for i, p := range cfg.SpawnPoints {
+		spawns[i] = Point{X: p.X * sx, Y: p.Y * sy}
+	}
+	teamSpawns := make(map[int][]Point, len(cfg.TeamSpawns))
+	for team, points := range cfg.TeamSpawns {
+		scaled := make([]Point, len(points))
+		for i, p := range points {
+			scaled[i] = Point{X: p.X * sx, Y: p.Y * sy}
+		}
+		teamSpawns[team] = scaled
+	}
+	points := make([]CapturePointConfig, len(cfg.CapturePoints))
+	for i, pc := range cfg.CapturePoints {
+		points[i] = pc
+		points[i].X *= sx
+		points[i].Y *= sy
+		points[i].Radius *= sr
+	}
+
+	cfg.Obstacles = obstacles
+	cfg.SpawnPoints = spawns
+	cfg.TeamSpawns = teamSpawns
+	cfg.CapturePoints = points
+}
+
+// validateWorld проверяет размеры мира и то, что точки появления и центры
+// точек захвата лежат внутри него
+func validateWorld(cfg Config) error {
+	if cfg.WorldWidth <= 0 || cfg.WorldHeight <= 0 {
+		return fmt.Errorf("размеры мира должны быть положительными")
+	}
+	for i, p := range cfg.SpawnPoints {
+		if !insideWorld(cfg, p.X, p.Y) {
+			return fmt.Errorf("точка появления %d (%.0f, %.0f) за пределами мира", i+1, p.X, p.Y)
+		}
+	}
+	for team, spawns := range cfg.TeamSpawns {
+		for i, p := range spawns {
+			if !insideWorld(cfg, p.X, p.Y) {
+				return fmt.Errorf("точка появления %d команды %d (%.0f, %.0f) за пределами мира", i+1, team, p.X, p.Y)
+			}
+		}
+	}
+	for i, cp := range cfg.CapturePoints {
+		if !insideWorld(cfg, cp.X, cp.Y) {
+			return fmt.Errorf("точка захвата %d (%.0f, %.0f) за пределами мира", i+1, cp.X, cp.Y)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig записывает JSON-конфигурацию во временный файл и возвращает путь
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultWorldSize(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, `{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.WorldWidth != referenceWorldWidth || cfg.WorldHeight != referenceWorldHeight {
+		t.Fatalf("размер мира по умолчанию %v×%v", cfg.WorldWidth, cfg.WorldHeight)
+	}
+}
+
+func TestLargerWorldClamping(t *testing.T) {
+	resetGame(t)
+	config.WorldWidth, config.WorldHeight = 2000, 1600
+
+	// За пределами мира по умолчанию, но внутри большого мира
+	if x, y, blocked := moveWithCollision(900, 700, 1900, 1500); x != 1900 || y != 1500 || blocked {
+		t.Fatalf("перемещение внутри мира: (%v, %v), blocked=%v", x, y, blocked)
+	}
+	// Граница большого мира по-прежнему работает как стена
+	if x, y, blocked := moveWithCollision(1900, 10, 2500, -5); x != 2000 || y != 0 || !blocked {
+		t.Fatalf("перемещение за границу: (%v, %v), blocked=%v", x, y, blocked)
+	}
+}
+
+func TestLargerWorldSpawn(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, `{"worldWidth": 2000, "worldHeight": 1600, "scaleMapToWorld": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetGame(t)
+	config = cfg
+
+	// Точка появления карты по умолчанию (400, 400) растянута вдвое
+	if x, y := spawnPosition(0); x != 800 || y != 800 {
+		t.Fatalf("точка появления (%v, %v), ожидалась (800, 800)", x, y)
+	}
+	startMatch()
+	if cp := capturePoints[1]; cp.X != 1600 || cp.Y != 1200 || cp.Radius != 100 {
+		t.Fatalf("точка захвата не растянута: (%v, %v) r=%v", cp.X, cp.Y, cp.Radius)
+	}
+
+	// Без точек появления игрок появляется в той же доле мира
+	config.SpawnPoints = nil
+	if x, y := spawnPosition(0); x != 800 || y != 800 {
+		t.Fatalf("точка появления без карты (%v, %v)", x, y)
+	}
+}
+
+func TestSpawnOutsideWorldRejected(t *testing.T) {
+	_, err := loadConfig(writeConfig(t, `{"spawnPoints": [{"x": 1500, "y": 100}]}`))
+	if err == nil {
+		t.Fatal("точка появления за пределами мира принята")
+	}
+}